@@ -0,0 +1,160 @@
+package pcre
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Cache holds compiled patterns for reuse, keyed by the pattern and its
+// compile and study flags.  When the cache grows beyond its budget, the
+// least recently used patterns are evicted.  The C memory of an evicted
+// pattern is freed once the last user has released it, so patterns
+// obtained from the cache stay valid until released.  A Cache may be
+// used from several goroutines at once.
+type Cache struct {
+	maxEntries int
+	maxBytes   int
+
+	mu      sync.Mutex
+	lru     *list.List // of *cacheEntry, most recently used first
+	entries map[cacheKey]*list.Element
+	bytes   int
+	stats   CacheStats
+}
+
+type cacheKey struct {
+	pattern    string
+	flags      int
+	studyFlags int
+}
+
+type cacheEntry struct {
+	key  cacheKey
+	re   Regexp
+	size int
+}
+
+// CacheStats holds statistics of a Cache.
+type CacheStats struct {
+	Hits      uint64 // Lookups that found a compiled pattern
+	Misses    uint64 // Lookups that compiled the pattern
+	Evictions uint64 // Patterns evicted to keep within the budget
+	Entries   int    // Patterns in the cache
+	Bytes     int    // Size of the patterns in the cache
+}
+
+// NewCache creates a cache holding at most maxEntries patterns whose
+// total size is at most maxBytes.  The size of a pattern is its size
+// as compiled, studied and JIT-compiled, as reported by Info.  A limit
+// of 0 means no limit.
+func NewCache(maxEntries, maxBytes int) *Cache {
+	return &Cache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		lru:        list.New(),
+		entries:    make(map[cacheKey]*list.Element),
+	}
+}
+
+// Get returns the pattern compiled with the compile flags and, unless
+// studyFlags is 0, studied with studyFlags.  The pattern is compiled
+// if it is not in the cache.  Call release when done with the pattern,
+// after which it must not be used.  Do not Close the pattern; the
+// cache frees it after eviction.  If compilation fails, the error
+// holds a *CompileError.
+func (c *Cache) Get(pattern string, flags, studyFlags int) (re Regexp, release func(), err error) {
+	key := cacheKey{pattern, flags, studyFlags}
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		if entry.re.c.acquire() {
+			c.lru.MoveToFront(elem)
+			c.stats.Hits++
+			c.mu.Unlock()
+			return entry.re, releaser(entry.re), nil
+		}
+		// The pattern was closed by a user.
+		c.remove(elem)
+	}
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	// Compile without holding the lock.  Concurrent misses may
+	// compile the same pattern, in which case the first one to
+	// finish is kept.
+	re, err = Compile(pattern, flags)
+	if err != nil {
+		return Regexp{}, nil, err
+	}
+	if studyFlags != 0 {
+		if err = re.Study(studyFlags); err != nil {
+			re.Close()
+			return Regexp{}, nil, err
+		}
+	}
+	info, err := re.Info()
+	if err != nil {
+		return Regexp{}, nil, err
+	}
+	re.c.acquire()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		if entry.re.c.acquire() {
+			c.lru.MoveToFront(elem)
+			re.c.release()
+			re.Close()
+			return entry.re, releaser(entry.re), nil
+		}
+		c.remove(elem)
+	}
+	entry := &cacheEntry{key, re, info.Size + info.StudySize + info.JITSize}
+	c.entries[key] = c.lru.PushFront(entry)
+	c.bytes += entry.size
+	for c.lru.Len() > 0 && (c.maxEntries > 0 && c.lru.Len() > c.maxEntries ||
+		c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+	return re, releaser(re), nil
+}
+
+// releaser returns a function that drops the reference to re taken by
+// Get.  Calls after the first do nothing.
+func releaser(re Regexp) func() {
+	var once sync.Once
+	return func() {
+		once.Do(re.c.release)
+	}
+}
+
+// remove drops an entry from the cache and closes its pattern.
+// c.mu must be held.
+func (c *Cache) remove(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.bytes -= entry.size
+	entry.re.Close()
+}
+
+// Purge removes all patterns from the cache.  Patterns still in use
+// are freed when released.
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.lru.Len() > 0 {
+		c.remove(c.lru.Back())
+	}
+}
+
+// Stats returns the statistics of the cache.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = c.lru.Len()
+	stats.Bytes = c.bytes
+	return stats
+}
@@ -0,0 +1,102 @@
+package pcre
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	c := NewCache(2, 0)
+	a, releaseA, err := c.Get(`a+`, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a2, releaseA2, _ := c.Get(`a+`, 0, 0)
+	if a.c != a2.c {
+		t.Error("hit returned another pattern")
+	}
+	releaseA2()
+	releaseA2() // no-op
+	if b, release, _ := c.Get(`a+`, CASELESS, 0); b.c == a.c {
+		t.Error("flags ignored in key")
+	} else {
+		release()
+	}
+	// Evicts a+, which stays usable until released.
+	_, releaseC, _ := c.Get(`c+`, 0, STUDY_JIT_COMPILE)
+	defer releaseC()
+	stats := c.Stats()
+	if stats.Bytes <= 0 {
+		t.Error("Bytes", stats.Bytes)
+	}
+	stats.Bytes = 0
+	if want := (CacheStats{Hits: 1, Misses: 3, Evictions: 1, Entries: 2}); stats != want {
+		t.Errorf("Stats = %+v, want %+v", stats, want)
+	}
+	m := a.NewMatcher()
+	if !m.MatchString("baab", 0) {
+		t.Error("evicted pattern freed while in use", m.Err())
+	}
+	releaseA()
+	if m.MatchString("baab", 0) || !errors.Is(m.Err(), ErrFreed) {
+		t.Error("released pattern not freed", m.Err())
+	}
+	if _, _, err := c.Get(`(`, 0, 0); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+}
+
+func TestCacheBytes(t *testing.T) {
+	re := MustCompile(`x{1,50}y`, 0)
+	info, _ := re.Info()
+	re.Close()
+	c := NewCache(0, 3*info.Size)
+	for i := 0; i < 10; i++ {
+		_, release, err := c.Get(fmt.Sprintf(`x{1,%d}y`, 50+i), 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+	}
+	stats := c.Stats()
+	if stats.Bytes > 3*info.Size || stats.Entries == 0 ||
+		stats.Evictions != uint64(10-stats.Entries) {
+		t.Errorf("Stats = %+v, budget %d", stats, 3*info.Size)
+	}
+	c.Purge()
+	if stats := c.Stats(); stats.Entries != 0 || stats.Bytes != 0 {
+		t.Errorf("Stats after Purge = %+v", stats)
+	}
+}
+
+func TestCacheConcurrent(t *testing.T) {
+	c := NewCache(4, 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				n := (i + j) % 6
+				re, release, err := c.Get(fmt.Sprintf(`(\d{%d})`, n+1), 0, 0)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				m := re.MatcherString("1234567", 0)
+				if m.GroupString(1) != "1234567"[:n+1] {
+					t.Error("GroupString", n, m.GroupString(1), m.Err())
+				}
+				release()
+			}
+		}(i)
+	}
+	wg.Wait()
+	stats := c.Stats()
+	if stats.Hits+stats.Misses != 8*200 || stats.Entries > 4 {
+		t.Errorf("Stats = %+v", stats)
+	}
+	c.Purge()
+}
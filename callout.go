@@ -0,0 +1,118 @@
+package pcre
+
+// #include <stdint.h>
+// #include "./pcre.h"
+import "C"
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// Callout describes the state of a match at a callout point, that is
+// a (?Cn) item in the pattern, or any item if the pattern was compiled
+// with AUTO_CALLOUT.  Offsets are byte positions in the subject.
+type Callout struct {
+	Number          int    // Callout number, 255 for automatic callouts
+	StartMatch      int    // Offset at which this match attempt started
+	CurrentPosition int    // Current offset in the subject
+	CaptureTop      int    // One more than the highest group captured so far
+	CaptureLast     int    // Most recently closed group, or -1
+	PatternPosition int    // Offset of the next item in the pattern
+	NextItemLength  int    // Length of the next item in the pattern
+	Mark            string // Most recently passed (*MARK) name, if any
+	// Offsets holds CaptureTop pairs of group offsets, as returned
+	// by Matcher.GroupIndices.  The pair for group 0 holds StartMatch
+	// and CurrentPosition; unset groups have offsets -1.
+	Offsets []int
+}
+
+// CalloutResult tells PCRE how to proceed after a callout.
+type CalloutResult int
+
+// Return values for a CalloutFunc.
+const (
+	// CalloutContinue continues matching normally.
+	CalloutContinue CalloutResult = 0
+	// CalloutFail fails at the current point, so that PCRE
+	// backtracks and tries other alternatives.
+	CalloutFail CalloutResult = 1
+	// CalloutAbort abandons the match; Exec returns ERROR_CALLOUT.
+	CalloutAbort CalloutResult = ERROR_CALLOUT
+)
+
+// CalloutFunc is called for every callout reached while matching.
+// It is called on the goroutine running the match.
+type CalloutFunc func(*Callout) CalloutResult
+
+// SetCallout registers f to be called for the callouts of matches
+// performed by this Matcher.  Passing nil removes the function.
+// Callouts of other Matchers, even on the same Regexp, are not affected.
+func (m *Matcher) SetCallout(f CalloutFunc) {
+	m.callout = f
+}
+
+// Matches in progress that have a callout are registered here, so that
+// the C callout function can find its Matcher from the handle in the
+// callout data without C keeping Go pointers.
+var callouts = struct {
+	sync.Mutex
+	next     uintptr
+	matchers map[uintptr]*Matcher
+}{matchers: make(map[uintptr]*Matcher)}
+
+func registerCallout(m *Matcher) uintptr {
+	callouts.Lock()
+	defer callouts.Unlock()
+	callouts.next++
+	if callouts.next == 0 {
+		callouts.next++
+	}
+	callouts.matchers[callouts.next] = m
+	return callouts.next
+}
+
+func unregisterCallout(handle uintptr) {
+	callouts.Lock()
+	delete(callouts.matchers, handle)
+	callouts.Unlock()
+}
+
+func lookupCallout(handle uintptr) *Matcher {
+	callouts.Lock()
+	defer callouts.Unlock()
+	return callouts.matchers[handle]
+}
+
+//export goCallout
+func goCallout(block *C.pcre_callout_block, handle C.uintptr_t) C.int {
+	m := lookupCallout(uintptr(handle))
+	if m == nil || m.callout == nil {
+		return 0
+	}
+	c := &Callout{
+		Number:          int(block.callout_number),
+		StartMatch:      int(block.start_match),
+		CurrentPosition: int(block.current_position),
+		CaptureTop:      int(block.capture_top),
+		CaptureLast:     int(block.capture_last),
+		PatternPosition: int(block.pattern_position),
+		NextItemLength:  int(block.next_item_length),
+	}
+	if block.mark != nil {
+		c.Mark = C.GoString((*C.char)(unsafe.Pointer(block.mark)))
+	}
+	c.Offsets = make([]int, 2*c.CaptureTop)
+	for i := 2; i < len(c.Offsets); i++ {
+		c.Offsets[i] = int(cIntAt(block.offset_vector, i))
+	}
+	c.Offsets[0], c.Offsets[1] = c.StartMatch, c.CurrentPosition
+	return C.int(m.callout(c))
+}
+
+// cIntAt returns the i'th element of the C array p, which may also
+// point into Go memory such as a Matcher's ovector.
+func cIntAt(p *C.int, i int) C.int {
+	return *(*C.int)(unsafe.Pointer(uintptr(unsafe.Pointer(p)) +
+		uintptr(i)*unsafe.Sizeof(*p)))
+}
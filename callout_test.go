@@ -0,0 +1,107 @@
+package pcre
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestCallout(t *testing.T) {
+	re := MustCompile(`(a)(?C1)(b)?(?C2)c`, 0)
+	defer re.FreeRegexp()
+	m := re.NewMatcher()
+	var seen []Callout
+	m.SetCallout(func(c *Callout) CalloutResult {
+		seen = append(seen, *c)
+		return CalloutContinue
+	})
+	if !m.MatchString("xabc", 0) {
+		t.Fatal("no match")
+	}
+	if len(seen) != 2 {
+		t.Fatal("callouts", len(seen))
+	}
+	c := seen[0]
+	if c.Number != 1 || c.StartMatch != 1 || c.CurrentPosition != 2 {
+		t.Error("first callout", c)
+	}
+	if !reflect.DeepEqual(c.Offsets, []int{1, 2, 1, 2}) {
+		t.Error("first callout offsets", c.Offsets)
+	}
+	if c = seen[1]; c.Number != 2 || c.CurrentPosition != 3 ||
+		c.CaptureTop != 3 || c.CaptureLast != 2 {
+		t.Error("second callout", c)
+	}
+
+	// Other matchers on the same pattern do not see the callouts.
+	if !re.MatcherString("xabc", 0).Matches() || len(seen) != 2 {
+		t.Error("callout called for another matcher")
+	}
+}
+
+func TestCalloutResult(t *testing.T) {
+	re := MustCompile(`\d+?(?C1)`, 0)
+	defer re.FreeRegexp()
+	m := re.NewMatcher()
+	// Reject matches that are not exactly three digits long.
+	m.SetCallout(func(c *Callout) CalloutResult {
+		if c.CurrentPosition-c.StartMatch != 3 {
+			return CalloutFail
+		}
+		return CalloutContinue
+	})
+	if !m.MatchString("12 34567 890", 0) {
+		t.Fatal("no match")
+	}
+	if g := m.GroupString(0); g != "345" {
+		t.Error("CalloutFail", g)
+	}
+
+	m.SetCallout(func(c *Callout) CalloutResult {
+		return CalloutAbort
+	})
+	if rc := m.ExecString("123", 0); rc != ERROR_CALLOUT {
+		t.Error("CalloutAbort", rc)
+	}
+}
+
+func TestCalloutMark(t *testing.T) {
+	re := MustCompile(`(*MARK:A)a(?C3)`, 0)
+	defer re.FreeRegexp()
+	m := re.NewMatcher()
+	var mark string
+	m.SetCallout(func(c *Callout) CalloutResult {
+		mark = c.Mark
+		return CalloutContinue
+	})
+	if !m.MatchString("a", 0) || mark != "A" {
+		t.Error("Mark", mark)
+	}
+}
+
+func TestCalloutConcurrent(t *testing.T) {
+	re := MustCompileJIT(`(\d)(?C1)`, 0, STUDY_JIT_COMPILE)
+	defer re.FreeRegexp()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			m := re.NewMatcher()
+			count := 0
+			m.SetCallout(func(c *Callout) CalloutResult {
+				count++
+				return CalloutFail
+			})
+			for j := 0; j < 100; j++ {
+				if m.MatchString("a1b2c3", 0) {
+					t.Error("matched despite CalloutFail")
+				}
+			}
+			if count != 300 {
+				t.Error("goroutine", id, "saw", count, "callouts")
+			}
+		}(i)
+	}
+	wg.Wait()
+}
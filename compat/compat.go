@@ -0,0 +1,939 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE-GO file.
+//
+// This file is derived from the regexp package of the Go standard
+// library: the matching loops of allMatches and replaceAll, expand,
+// extract and Split, and the documentation of the exported API, follow
+// regexp.go.
+
+// Package compat provides a Regexp type with the same method set as the
+// standard library's regexp.Regexp, backed by PCRE.  Code using
+// *regexp.Regexp can switch to PCRE, and gain lookbehind, backreferences
+// and possessive quantifiers, by changing an import.
+//
+// Patterns are compiled with the UTF8 and DOLLAR_ENDONLY flags, so that
+// '.' matches a whole UTF-8 character and '$' without (?m) matches only
+// at the end of the text, as in Go.  As in Go, each byte of a subject
+// that is not valid UTF-8 matches as U+FFFD.  Matching is
+// leftmost-first, as in Perl, unless Longest is called.
+//
+// Unlike Go's, PCRE matching backtracks, and gives up on a match that
+// exceeds its match or recursion limit.  Rather than reporting no match
+// in that case, the matching methods panic with the *pcre.MatchError.
+package compat
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	pcre "github.com/scorpionknifes/go-pcre"
+)
+
+// compileFlags are the PCRE flags used to get Go-like semantics.
+const compileFlags = pcre.UTF8 | pcre.DOLLAR_ENDONLY
+
+// Regexp is the representation of a compiled regular expression.
+// A Regexp is safe for concurrent use by multiple goroutines.
+type Regexp struct {
+	expr    string
+	re      pcre.Regexp
+	names   []string
+	longest bool
+	// After Longest, lre is the pattern used for matching, and
+	// lend is the pattern offset of the callout at its end.
+	lre  pcre.Regexp
+	lend int
+}
+
+// Compile parses a regular expression and returns, if successful,
+// a Regexp object that can be used to match against text.
+func Compile(expr string) (*Regexp, error) {
+	re, err := pcre.Compile(expr, compileFlags)
+	if err != nil {
+		return nil, err
+	}
+	return &Regexp{
+		expr:  expr,
+		re:    re,
+		names: re.SubexpNames(),
+	}, nil
+}
+
+// MustCompile is like Compile but panics if the expression cannot be
+// parsed.
+func MustCompile(str string) *Regexp {
+	re, err := Compile(str)
+	if err != nil {
+		panic(`compat: Compile(` + quote(str) + `): ` + err.Error())
+	}
+	return re
+}
+
+func quote(s string) string {
+	if strconv.CanBackquote(s) {
+		return "`" + s + "`"
+	}
+	return strconv.Quote(s)
+}
+
+// MatchString reports whether the string s contains any match of the
+// regular expression pattern.
+func MatchString(pattern string, s string) (matched bool, err error) {
+	re, err := Compile(pattern)
+	if err != nil {
+		return false, err
+	}
+	return re.MatchString(s), nil
+}
+
+// Match reports whether the byte slice b contains any match of the
+// regular expression pattern.
+func Match(pattern string, b []byte) (matched bool, err error) {
+	re, err := Compile(pattern)
+	if err != nil {
+		return false, err
+	}
+	return re.Match(b), nil
+}
+
+// MatchReader reports whether the text returned by the RuneReader
+// contains any match of the regular expression pattern.
+func MatchReader(pattern string, r io.RuneReader) (matched bool, err error) {
+	re, err := Compile(pattern)
+	if err != nil {
+		return false, err
+	}
+	return re.MatchReader(r), nil
+}
+
+// QuoteMeta returns a string that escapes all regular expression
+// metacharacters inside the argument text.
+func QuoteMeta(s string) string {
+	var b strings.Builder
+	b.Grow(2 * len(s))
+	for i := 0; i < len(s); i++ {
+		if special(s[i]) {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func special(b byte) bool {
+	return strings.IndexByte(`\.+*?()|[]{}^$`, b) >= 0
+}
+
+// String returns the source text used to compile the regular expression.
+func (re *Regexp) String() string {
+	return re.expr
+}
+
+// Copy returns a new Regexp object copied from re.
+//
+// Deprecated: as with the standard library, a Regexp is safe for
+// concurrent use and there is no need to copy it.
+func (re *Regexp) Copy() *Regexp {
+	re2 := *re
+	return &re2
+}
+
+// Longest makes future searches prefer leftmost-longest matches.
+// That is, when matching against text, the regexp returns a match that
+// begins as early as possible in the input (leftmost), and among those
+// it chooses a match that is as long as possible.  Its subexpressions
+// are those of the first such match in leftmost-first order.  This
+// method modifies the Regexp and may not be called concurrently with
+// any other methods.
+//
+// PCRE finds the longest match by trying every way in which the
+// pattern matches at the leftmost position, which may be much slower
+// than leftmost-first matching.
+func (re *Regexp) Longest() {
+	if re.longest {
+		return
+	}
+	// A callout at the end of the pattern records each match, see
+	// recordLongest.  Options such as (*CRLF) must start the
+	// pattern, so it cannot always be wrapped; AUTO_CALLOUT then
+	// adds the callout at its end.
+	src := `(?:` + re.expr + `\E)(?C1)`
+	lre, err := pcre.Compile(src, compileFlags)
+	if err != nil {
+		src = re.expr
+		lre = pcre.MustCompile(src, compileFlags|pcre.AUTO_CALLOUT)
+	}
+	re.lre, re.lend, re.longest = lre, len(src), true
+}
+
+// recordLongest returns the callout function of a match after Longest.
+// At the end of the pattern, it records the match in *loc if it is the
+// longest so far, and fails it, so that PCRE goes on to try the other
+// ways of matching.  Once a match starting later than the recorded one
+// is found, it abandons the search.
+func (re *Regexp) recordLongest(loc *[]int) pcre.CalloutFunc {
+	start := -1
+	return func(c *pcre.Callout) pcre.CalloutResult {
+		if c.PatternPosition != re.lend {
+			return pcre.CalloutContinue
+		}
+		if start >= 0 && c.StartMatch != start {
+			return pcre.CalloutAbort
+		}
+		if start < 0 || c.CurrentPosition > (*loc)[1] {
+			start = c.StartMatch
+			l := make([]int, 2*len(re.names))
+			for i := range l {
+				l[i] = -1
+			}
+			copy(l, c.Offsets)
+			*loc = l
+		}
+		return pcre.CalloutFail
+	}
+}
+
+// newMatcher returns a matcher for a search.
+func (re *Regexp) newMatcher() *pcre.Matcher {
+	if re.longest {
+		return re.lre.NewMatcher()
+	}
+	return re.re.NewMatcher()
+}
+
+// NumSubexp returns the number of parenthesized subexpressions in this
+// Regexp.
+func (re *Regexp) NumSubexp() int {
+	return len(re.names) - 1
+}
+
+// SubexpNames returns the names of the parenthesized subexpressions in
+// this Regexp.  names[0] is always the empty string.  The slice should
+// not be modified.
+func (re *Regexp) SubexpNames() []string {
+	return re.names
+}
+
+// SubexpIndex returns the index of the first subexpression with the
+// given name, or -1 if there is no subexpression with that name.
+func (re *Regexp) SubexpIndex(name string) int {
+	if name != "" {
+		for i, s := range re.names {
+			if name == s {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// LiteralPrefix returns a literal string that must begin any match of
+// the regular expression re.  It returns the boolean true if the
+// literal string comprises the entire regular expression.  The prefix
+// is found by scanning the source text up to the first metacharacter,
+// so it may be shorter than the one reported by the standard library.
+func (re *Regexp) LiteralPrefix() (prefix string, complete bool) {
+	var b strings.Builder
+	for i := 0; i < len(re.expr); i++ {
+		c := re.expr[i]
+		if special(c) {
+			if c == '+' {
+				return b.String(), false
+			}
+			// Other quantifiers make the preceding character
+			// optional, so it is not part of the prefix.
+			if strings.IndexByte("*?{", c) >= 0 && b.Len() > 0 {
+				p := b.String()
+				_, size := utf8.DecodeLastRuneInString(p)
+				return p[:len(p)-size], false
+			}
+			return b.String(), false
+		}
+		b.WriteByte(c)
+	}
+	return b.String(), true
+}
+
+// MarshalText implements encoding.TextMarshaler.  The output matches
+// that of calling the String method.
+func (re *Regexp) MarshalText() ([]byte, error) {
+	return []byte(re.String()), nil
+}
+
+// AppendText implements encoding.TextAppender.  The output matches
+// that of calling the String method.
+func (re *Regexp) AppendText(b []byte) ([]byte, error) {
+	return append(b, re.String()...), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler by calling Compile
+// on the encoded value.
+func (re *Regexp) UnmarshalText(text []byte) error {
+	newRE, err := Compile(string(text))
+	if err != nil {
+		return err
+	}
+	*re = *newRE
+	return nil
+}
+
+// input is the subject of a search.  PCRE rejects subjects that are
+// not valid UTF-8, which Go decodes by taking each invalid byte as
+// U+FFFD.  To match them the same way, PCRE is given a copy of the
+// subject in which the invalid bytes are replaced by U+FFFD, and the
+// offsets are mapped between the two.
+type input struct {
+	b         []byte // the subject, or nil if it is s
+	s         string
+	fixed     []byte // the copy of an invalid subject, or nil
+	toFixed   []int  // offsets in fixed of those in the subject
+	fromFixed []int  // offsets in the subject of those in fixed
+}
+
+func newInput(b []byte, s string) *input {
+	in := &input{b: b, s: s}
+	if b != nil && utf8.Valid(b) || b == nil && utf8.ValidString(s) {
+		return in
+	}
+	if b != nil {
+		s = string(b)
+	}
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			in.toFixed = append(in.toFixed, len(in.fixed))
+			in.fromFixed = append(in.fromFixed, i, i, i)
+			in.fixed = append(in.fixed, string(utf8.RuneError)...)
+		} else {
+			for j := 0; j < size; j++ {
+				in.toFixed = append(in.toFixed, len(in.fixed)+j)
+				in.fromFixed = append(in.fromFixed, i+j)
+			}
+			in.fixed = append(in.fixed, s[i:i+size]...)
+		}
+		i += size
+	}
+	in.toFixed = append(in.toFixed, len(in.fixed))
+	in.fromFixed = append(in.fromFixed, len(s))
+	return in
+}
+
+// len returns the length of the subject.
+func (in *input) len() int {
+	if in.b != nil {
+		return len(in.b)
+	}
+	return len(in.s)
+}
+
+// runeWidth returns the width of the character at pos, or 0 at the end.
+func (in *input) runeWidth(pos int) (width int) {
+	if in.b != nil {
+		_, width = utf8.DecodeRune(in.b[pos:])
+	} else {
+		_, width = utf8.DecodeRuneInString(in.s[pos:])
+	}
+	return
+}
+
+// match runs the pattern against the input, starting at pos.  It
+// returns the submatch index pairs, or nil if there is no match.
+func (re *Regexp) match(m *pcre.Matcher, in *input, pos int) []int {
+	var loc []int
+	if re.longest {
+		// Clear the error of a previous search, which the
+		// callout may have abandoned.
+		m.Init(&re.lre)
+		m.SetCallout(re.recordLongest(&loc))
+	}
+	// newInput made the subject valid UTF-8, and pos is at the start
+	// of a character, so PCRE need not check the subject again on
+	// each search.
+	var ok bool
+	switch {
+	case in.fixed != nil:
+		ok = m.MatchFrom(in.fixed, in.toFixed[pos], pcre.NO_UTF8_CHECK)
+	case in.b != nil:
+		ok = m.MatchFrom(in.b, pos, pcre.NO_UTF8_CHECK)
+	default:
+		ok = m.MatchStringFrom(in.s, pos, pcre.NO_UTF8_CHECK)
+	}
+	if re.longest {
+		// The callout fails every match, and abandons the search
+		// once the longest one is known.
+		ok = loc != nil
+	}
+	if !ok {
+		if err := m.Err(); err != nil {
+			panic(err)
+		}
+		return nil
+	}
+	if loc == nil {
+		loc = make([]int, 2*len(re.names))
+		for i := range re.names {
+			if g := m.GroupIndices(i); g != nil {
+				loc[2*i], loc[2*i+1] = g[0], g[1]
+			} else {
+				loc[2*i], loc[2*i+1] = -1, -1
+			}
+		}
+	}
+	if in.fixed != nil {
+		for i, off := range loc {
+			if off >= 0 {
+				loc[i] = in.fromFixed[off]
+			}
+		}
+	}
+	return loc
+}
+
+func (re *Regexp) doExecute(b []byte, s string) []int {
+	return re.match(re.newMatcher(), newInput(b, s), 0)
+}
+
+// readAll collects the text of a RuneReader into a byte slice.
+func readAll(r io.RuneReader) []byte {
+	var buf bytes.Buffer
+	for {
+		c, _, err := r.ReadRune()
+		if err != nil {
+			return buf.Bytes()
+		}
+		buf.WriteRune(c)
+	}
+}
+
+// allMatches calls deliver for up to n successive non-overlapping
+// matches in b, or s if b is nil.  An empty match abutting a preceding
+// match is ignored.
+func (re *Regexp) allMatches(s string, b []byte, n int, deliver func([]int)) {
+	in := newInput(b, s)
+	end := in.len()
+	m := re.newMatcher()
+	for pos, i, prevMatchEnd := 0, 0, -1; i < n && pos <= end; {
+		matches := re.match(m, in, pos)
+		if len(matches) == 0 {
+			break
+		}
+		accept := true
+		if matches[1] == pos {
+			// We've found an empty match.
+			if matches[0] == prevMatchEnd {
+				accept = false
+			}
+			if width := in.runeWidth(pos); width > 0 {
+				pos += width
+			} else {
+				pos = end + 1
+			}
+		} else {
+			pos = matches[1]
+		}
+		prevMatchEnd = matches[1]
+		if accept {
+			deliver(matches)
+			i++
+		}
+	}
+}
+
+// MatchReader reports whether the text returned by the RuneReader
+// contains any match of the regular expression re.
+func (re *Regexp) MatchReader(r io.RuneReader) bool {
+	return re.Match(readAll(r))
+}
+
+// MatchString reports whether the string s contains any match of the
+// regular expression re.
+func (re *Regexp) MatchString(s string) bool {
+	return re.doExecute(nil, s) != nil
+}
+
+// Match reports whether the byte slice b contains any match of the
+// regular expression re.
+func (re *Regexp) Match(b []byte) bool {
+	if b == nil {
+		b = []byte{}
+	}
+	return re.doExecute(b, "") != nil
+}
+
+// Find returns a slice holding the text of the leftmost match in b of
+// the regular expression.  A return value of nil indicates no match.
+func (re *Regexp) Find(b []byte) []byte {
+	a := re.doExecute(b, "")
+	if a == nil {
+		return nil
+	}
+	return b[a[0]:a[1]:a[1]]
+}
+
+// FindIndex returns a two-element slice of integers defining the
+// location of the leftmost match in b of the regular expression.
+// A return value of nil indicates no match.
+func (re *Regexp) FindIndex(b []byte) (loc []int) {
+	a := re.doExecute(b, "")
+	if a == nil {
+		return nil
+	}
+	return a[0:2]
+}
+
+// FindString returns a string holding the text of the leftmost match in
+// s of the regular expression.  If there is no match, the return value
+// is an empty string, but it will also be empty if the regular
+// expression successfully matches an empty string.
+func (re *Regexp) FindString(s string) string {
+	a := re.doExecute(nil, s)
+	if a == nil {
+		return ""
+	}
+	return s[a[0]:a[1]]
+}
+
+// FindStringIndex returns a two-element slice of integers defining the
+// location of the leftmost match in s of the regular expression.
+// A return value of nil indicates no match.
+func (re *Regexp) FindStringIndex(s string) (loc []int) {
+	a := re.doExecute(nil, s)
+	if a == nil {
+		return nil
+	}
+	return a[0:2]
+}
+
+// FindReaderIndex returns a two-element slice of integers defining the
+// location of the leftmost match of the regular expression in text read
+// from the RuneReader.  A return value of nil indicates no match.
+func (re *Regexp) FindReaderIndex(r io.RuneReader) (loc []int) {
+	return re.FindIndex(readAll(r))
+}
+
+// FindSubmatch returns a slice of slices holding the text of the
+// leftmost match of the regular expression in b and the matches, if any,
+// of its subexpressions.  A return value of nil indicates no match.
+func (re *Regexp) FindSubmatch(b []byte) [][]byte {
+	a := re.doExecute(b, "")
+	if a == nil {
+		return nil
+	}
+	ret := make([][]byte, len(re.names))
+	for i := range ret {
+		if 2*i < len(a) && a[2*i] >= 0 {
+			ret[i] = b[a[2*i]:a[2*i+1]:a[2*i+1]]
+		}
+	}
+	return ret
+}
+
+// FindSubmatchIndex returns a slice holding the index pairs identifying
+// the leftmost match of the regular expression in b and the matches, if
+// any, of its subexpressions.  A return value of nil indicates no match.
+func (re *Regexp) FindSubmatchIndex(b []byte) []int {
+	return re.doExecute(b, "")
+}
+
+// FindStringSubmatch returns a slice of strings holding the text of the
+// leftmost match of the regular expression in s and the matches, if any,
+// of its subexpressions.  A return value of nil indicates no match.
+func (re *Regexp) FindStringSubmatch(s string) []string {
+	a := re.doExecute(nil, s)
+	if a == nil {
+		return nil
+	}
+	ret := make([]string, len(re.names))
+	for i := range ret {
+		if 2*i < len(a) && a[2*i] >= 0 {
+			ret[i] = s[a[2*i]:a[2*i+1]]
+		}
+	}
+	return ret
+}
+
+// FindStringSubmatchIndex returns a slice holding the index pairs
+// identifying the leftmost match of the regular expression in s and the
+// matches, if any, of its subexpressions.  A return value of nil
+// indicates no match.
+func (re *Regexp) FindStringSubmatchIndex(s string) []int {
+	return re.doExecute(nil, s)
+}
+
+// FindReaderSubmatchIndex returns a slice holding the index pairs
+// identifying the leftmost match of the regular expression in text read
+// from the RuneReader, and the matches, if any, of its subexpressions.
+// A return value of nil indicates no match.
+func (re *Regexp) FindReaderSubmatchIndex(r io.RuneReader) []int {
+	return re.FindSubmatchIndex(readAll(r))
+}
+
+// FindAll is the 'All' version of Find; it returns a slice of all
+// successive matches of the expression.  If n >= 0, at most n matches
+// are returned.  A return value of nil indicates no match.
+func (re *Regexp) FindAll(b []byte, n int) [][]byte {
+	if n < 0 {
+		n = len(b) + 1
+	}
+	var result [][]byte
+	re.allMatches("", b, n, func(match []int) {
+		result = append(result, b[match[0]:match[1]:match[1]])
+	})
+	return result
+}
+
+// FindAllIndex is the 'All' version of FindIndex.
+func (re *Regexp) FindAllIndex(b []byte, n int) [][]int {
+	if n < 0 {
+		n = len(b) + 1
+	}
+	var result [][]int
+	re.allMatches("", b, n, func(match []int) {
+		result = append(result, match[0:2])
+	})
+	return result
+}
+
+// FindAllString is the 'All' version of FindString.
+func (re *Regexp) FindAllString(s string, n int) []string {
+	if n < 0 {
+		n = len(s) + 1
+	}
+	var result []string
+	re.allMatches(s, nil, n, func(match []int) {
+		result = append(result, s[match[0]:match[1]])
+	})
+	return result
+}
+
+// FindAllStringIndex is the 'All' version of FindStringIndex.
+func (re *Regexp) FindAllStringIndex(s string, n int) [][]int {
+	if n < 0 {
+		n = len(s) + 1
+	}
+	var result [][]int
+	re.allMatches(s, nil, n, func(match []int) {
+		result = append(result, match[0:2])
+	})
+	return result
+}
+
+// FindAllSubmatch is the 'All' version of FindSubmatch.
+func (re *Regexp) FindAllSubmatch(b []byte, n int) [][][]byte {
+	if n < 0 {
+		n = len(b) + 1
+	}
+	var result [][][]byte
+	re.allMatches("", b, n, func(match []int) {
+		slice := make([][]byte, len(match)/2)
+		for j := range slice {
+			if match[2*j] >= 0 {
+				slice[j] = b[match[2*j]:match[2*j+1]:match[2*j+1]]
+			}
+		}
+		result = append(result, slice)
+	})
+	return result
+}
+
+// FindAllSubmatchIndex is the 'All' version of FindSubmatchIndex.
+func (re *Regexp) FindAllSubmatchIndex(b []byte, n int) [][]int {
+	if n < 0 {
+		n = len(b) + 1
+	}
+	var result [][]int
+	re.allMatches("", b, n, func(match []int) {
+		result = append(result, match)
+	})
+	return result
+}
+
+// FindAllStringSubmatch is the 'All' version of FindStringSubmatch.
+func (re *Regexp) FindAllStringSubmatch(s string, n int) [][]string {
+	if n < 0 {
+		n = len(s) + 1
+	}
+	var result [][]string
+	re.allMatches(s, nil, n, func(match []int) {
+		slice := make([]string, len(match)/2)
+		for j := range slice {
+			if match[2*j] >= 0 {
+				slice[j] = s[match[2*j]:match[2*j+1]]
+			}
+		}
+		result = append(result, slice)
+	})
+	return result
+}
+
+// FindAllStringSubmatchIndex is the 'All' version of
+// FindStringSubmatchIndex.
+func (re *Regexp) FindAllStringSubmatchIndex(s string, n int) [][]int {
+	if n < 0 {
+		n = len(s) + 1
+	}
+	var result [][]int
+	re.allMatches(s, nil, n, func(match []int) {
+		result = append(result, match)
+	})
+	return result
+}
+
+// replaceAll calls repl for every match of the expression in bsrc, or
+// src if bsrc is nil, and returns the text with the matches replaced.
+// An empty match immediately after a preceding match is not replaced.
+func (re *Regexp) replaceAll(bsrc []byte, src string, repl func(dst []byte, m []int) []byte) []byte {
+	lastMatchEnd := 0
+	searchPos := 0
+	var buf []byte
+	in := newInput(bsrc, src)
+	end := in.len()
+	m := re.newMatcher()
+	for searchPos <= end {
+		a := re.match(m, in, searchPos)
+		if len(a) == 0 {
+			break
+		}
+		if bsrc != nil {
+			buf = append(buf, bsrc[lastMatchEnd:a[0]]...)
+		} else {
+			buf = append(buf, src[lastMatchEnd:a[0]]...)
+		}
+		if a[1] > lastMatchEnd || a[0] == 0 {
+			buf = repl(buf, a)
+		}
+		lastMatchEnd = a[1]
+		// Advance past this match; always advance at least one character.
+		width := in.runeWidth(searchPos)
+		if searchPos+width > a[1] {
+			searchPos += width
+		} else if searchPos+1 > a[1] {
+			searchPos++
+		} else {
+			searchPos = a[1]
+		}
+	}
+	if bsrc != nil {
+		buf = append(buf, bsrc[lastMatchEnd:]...)
+	} else {
+		buf = append(buf, src[lastMatchEnd:]...)
+	}
+	return buf
+}
+
+// ReplaceAllString returns a copy of src, replacing matches of the
+// Regexp with the replacement string repl.  Inside repl, $ signs are
+// interpreted as in Expand.
+func (re *Regexp) ReplaceAllString(src, repl string) string {
+	b := re.replaceAll(nil, src, func(dst []byte, match []int) []byte {
+		return re.expand(dst, repl, nil, src, match)
+	})
+	return string(b)
+}
+
+// ReplaceAllLiteralString returns a copy of src, replacing matches of
+// the Regexp with the replacement string repl.  The replacement repl is
+// substituted directly, without using Expand.
+func (re *Regexp) ReplaceAllLiteralString(src, repl string) string {
+	return string(re.replaceAll(nil, src, func(dst []byte, match []int) []byte {
+		return append(dst, repl...)
+	}))
+}
+
+// ReplaceAllStringFunc returns a copy of src in which all matches of the
+// Regexp have been replaced by the return value of function repl applied
+// to the matched substring.
+func (re *Regexp) ReplaceAllStringFunc(src string, repl func(string) string) string {
+	b := re.replaceAll(nil, src, func(dst []byte, match []int) []byte {
+		return append(dst, repl(src[match[0]:match[1]])...)
+	})
+	return string(b)
+}
+
+// ReplaceAll returns a copy of src, replacing matches of the Regexp with
+// the replacement text repl.  Inside repl, $ signs are interpreted as in
+// Expand.
+func (re *Regexp) ReplaceAll(src, repl []byte) []byte {
+	if src == nil {
+		src = []byte{}
+	}
+	return re.replaceAll(src, "", func(dst []byte, match []int) []byte {
+		return re.expand(dst, string(repl), src, "", match)
+	})
+}
+
+// ReplaceAllLiteral returns a copy of src, replacing matches of the
+// Regexp with the replacement bytes repl.  The replacement repl is
+// substituted directly, without using Expand.
+func (re *Regexp) ReplaceAllLiteral(src, repl []byte) []byte {
+	if src == nil {
+		src = []byte{}
+	}
+	return re.replaceAll(src, "", func(dst []byte, match []int) []byte {
+		return append(dst, repl...)
+	})
+}
+
+// ReplaceAllFunc returns a copy of src in which all matches of the
+// Regexp have been replaced by the return value of function repl applied
+// to the matched byte slice.
+func (re *Regexp) ReplaceAllFunc(src []byte, repl func([]byte) []byte) []byte {
+	if src == nil {
+		src = []byte{}
+	}
+	return re.replaceAll(src, "", func(dst []byte, match []int) []byte {
+		return append(dst, repl(src[match[0]:match[1]])...)
+	})
+}
+
+// Expand appends template to dst and returns the result; during the
+// append, Expand replaces variables in the template with corresponding
+// matches drawn from src.  The match slice should have been returned by
+// FindSubmatchIndex.
+//
+// In the template, a variable is denoted by a substring of the form
+// $name or ${name}, where name is a non-empty sequence of letters,
+// digits, and underscores.  A purely numeric name like $1 refers to the
+// submatch with the corresponding index; other names refer to capturing
+// parentheses named with the (?P<name>...) syntax.  A reference to an
+// out of range or unmatched index or a name that is not present in the
+// regular expression is replaced with an empty slice.  To insert a
+// literal $ in the output, use $$ in the template.
+func (re *Regexp) Expand(dst []byte, template []byte, src []byte, match []int) []byte {
+	if src == nil {
+		src = []byte{}
+	}
+	return re.expand(dst, string(template), src, "", match)
+}
+
+// ExpandString is like Expand but the template and source are strings.
+func (re *Regexp) ExpandString(dst []byte, template string, src string, match []int) []byte {
+	return re.expand(dst, template, nil, src, match)
+}
+
+func (re *Regexp) expand(dst []byte, template string, bsrc []byte, src string, match []int) []byte {
+	for len(template) > 0 {
+		i := strings.IndexByte(template, '$')
+		if i < 0 {
+			break
+		}
+		dst = append(dst, template[:i]...)
+		template = template[i+1:]
+		if template != "" && template[0] == '$' {
+			// Treat $$ as $.
+			dst = append(dst, '$')
+			template = template[1:]
+			continue
+		}
+		name, num, rest, ok := extract(template)
+		if !ok {
+			// Malformed; treat $ as raw text.
+			dst = append(dst, '$')
+			continue
+		}
+		template = rest
+		if num < 0 {
+			num = re.SubexpIndex(name)
+		}
+		if num >= 0 && 2*num+1 < len(match) && match[2*num] >= 0 {
+			if bsrc != nil {
+				dst = append(dst, bsrc[match[2*num]:match[2*num+1]]...)
+			} else {
+				dst = append(dst, src[match[2*num]:match[2*num+1]]...)
+			}
+		}
+	}
+	return append(dst, template...)
+}
+
+// extract returns the name from a leading "name" or "{name}" in str.
+// (The $ has already been removed by the caller.)  If it is a number,
+// extract returns num set to that number; otherwise num = -1.
+func extract(str string) (name string, num int, rest string, ok bool) {
+	if str == "" {
+		return
+	}
+	brace := false
+	if str[0] == '{' {
+		brace = true
+		str = str[1:]
+	}
+	i := 0
+	for i < len(str) {
+		r, size := utf8.DecodeRuneInString(str[i:])
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			break
+		}
+		i += size
+	}
+	if i == 0 {
+		// empty name is not okay
+		return
+	}
+	name = str[:i]
+	if brace {
+		if i >= len(str) || str[i] != '}' {
+			// missing closing brace
+			return
+		}
+		i++
+	}
+
+	// Parse number.
+	num = 0
+	for j := 0; j < len(name); j++ {
+		if name[j] < '0' || '9' < name[j] || num >= 1e8 {
+			num = -1
+			break
+		}
+		num = num*10 + int(name[j]) - '0'
+	}
+	// Disallow leading zeros.
+	if name[0] == '0' && len(name) > 1 {
+		num = -1
+	}
+
+	rest = str[i:]
+	ok = true
+	return
+}
+
+// Split slices s into substrings separated by the expression and
+// returns a slice of the substrings between those expression matches.
+//
+// The count determines the number of substrings to return:
+//
+//	n > 0: at most n substrings; the last substring will be the unsplit remainder.
+//	n == 0: the result is nil (zero substrings)
+//	n < 0: all substrings
+func (re *Regexp) Split(s string, n int) []string {
+	if n == 0 {
+		return nil
+	}
+	if len(re.expr) > 0 && len(s) == 0 {
+		return []string{""}
+	}
+	matches := re.FindAllStringIndex(s, n)
+	result := make([]string, 0, len(matches))
+	beg := 0
+	end := 0
+	for _, match := range matches {
+		if n > 0 && len(result) == n-1 {
+			break
+		}
+		end = match[0]
+		if match[1] != 0 {
+			result = append(result, s[beg:end])
+		}
+		beg = match[1]
+	}
+	if end != len(s) {
+		result = append(result, s[beg:])
+	}
+	return result
+}
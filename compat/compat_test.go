@@ -0,0 +1,192 @@
+package compat
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	pcre "github.com/scorpionknifes/go-pcre"
+)
+
+var parityTests = []struct {
+	pattern string
+	subject string
+}{
+	{`a*`, "baaab"},
+	{`\w*`, "cat dog"},
+	{`(a)(x)?(\d+)`, "-a12-ax3-a4"},
+	{`(?P<first>\w+)\s(?P<last>\w+)`, "Ada Lovelace, Alan Turing"},
+	{`.`, "héllo"},
+	{`x*`, "ñaña"},
+	{`^$`, ""},
+	{`b$`, "ab\n"},
+	{`(?m)^\w`, "one\ntwo\nthree"},
+	{`x`, "\xffx"},
+	{`.`, "a\xffb\xc3"},
+	{`[^a]+`, "\xe2\x82a\xff"},
+	{`x*`, "\xff\xfe"},
+}
+
+func TestParity(t *testing.T) {
+	for _, tt := range parityTests {
+		want := regexp.MustCompile(tt.pattern)
+		got := MustCompile(tt.pattern)
+		if w, g := want.FindAllStringSubmatchIndex(tt.subject, -1),
+			got.FindAllStringSubmatchIndex(tt.subject, -1); !reflect.DeepEqual(w, g) {
+			t.Errorf("%s on %q: FindAllStringSubmatchIndex %v, want %v",
+				tt.pattern, tt.subject, g, w)
+		}
+		if w, g := want.FindAllSubmatch([]byte(tt.subject), 2),
+			got.FindAllSubmatch([]byte(tt.subject), 2); !reflect.DeepEqual(w, g) {
+			t.Errorf("%s on %q: FindAllSubmatch %q, want %q",
+				tt.pattern, tt.subject, g, w)
+		}
+		if w, g := want.ReplaceAllString(tt.subject, "<$0>"),
+			got.ReplaceAllString(tt.subject, "<$0>"); w != g {
+			t.Errorf("%s on %q: ReplaceAllString %q, want %q",
+				tt.pattern, tt.subject, g, w)
+		}
+		if w, g := want.Split(tt.subject, -1),
+			got.Split(tt.subject, -1); !reflect.DeepEqual(w, g) {
+			t.Errorf("%s on %q: Split %q, want %q",
+				tt.pattern, tt.subject, g, w)
+		}
+		if w, g := want.MatchString(tt.subject),
+			got.MatchString(tt.subject); w != g {
+			t.Errorf("%s on %q: MatchString %v, want %v",
+				tt.pattern, tt.subject, g, w)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	re := MustCompile(`(?P<first>\w+)\s(?P<last>\w+)`)
+	if s := re.ReplaceAllString("Ada Lovelace", "${last}, $first $$1 $3"); s != "Lovelace, Ada $1 " {
+		t.Error("ReplaceAllString", s)
+	}
+	if n := re.NumSubexp(); n != 2 {
+		t.Error("NumSubexp", n)
+	}
+	if i := re.SubexpIndex("last"); i != 2 {
+		t.Error("SubexpIndex", i)
+	}
+	if s := re.ReplaceAllStringFunc("ada lovelace", strings.ToUpper); s != "ADA LOVELACE" {
+		t.Error("ReplaceAllStringFunc", s)
+	}
+}
+
+func TestPCREFeatures(t *testing.T) {
+	re := MustCompile(`(?<=\$)\d+`)
+	if s := re.FindAllString("$10 and 20 and $30", -1); !reflect.DeepEqual(s, []string{"10", "30"}) {
+		t.Error("lookbehind", s)
+	}
+	re = MustCompile(`(\w)\1`)
+	if s := re.FindString("abccd"); s != "cc" {
+		t.Error("backreference", s)
+	}
+	if re.String() != `(\w)\1` {
+		t.Error("String", re.String())
+	}
+}
+
+func TestLongest(t *testing.T) {
+	for _, tt := range []struct {
+		pattern string
+		subject string
+	}{
+		{`a(|b)`, "ab"},
+		{`(a|ab)(c|bcd)(d*)`, "abcd"},
+		{`x*|(\w+)`, "-abc xx"},
+		{`(?i)go|golang`, "GOLANG gopher"},
+		{`(?m)^\w+|\w+$`, "ab cd\nef"},
+		{`.|\xff`, "\xffa"},
+	} {
+		want := regexp.MustCompile(tt.pattern)
+		want.Longest()
+		got := MustCompile(tt.pattern)
+		got.Longest()
+		if w, g := want.FindAllStringSubmatchIndex(tt.subject, -1),
+			got.FindAllStringSubmatchIndex(tt.subject, -1); !reflect.DeepEqual(w, g) {
+			t.Errorf("%s on %q: FindAllStringSubmatchIndex %v, want %v",
+				tt.pattern, tt.subject, g, w)
+		}
+		if w, g := want.ReplaceAllString(tt.subject, "<$0>"),
+			got.ReplaceAllString(tt.subject, "<$0>"); w != g {
+			t.Errorf("%s on %q: ReplaceAllString %q, want %q",
+				tt.pattern, tt.subject, g, w)
+		}
+	}
+	// Leading options cannot be wrapped, and are matched with
+	// automatic callouts instead.
+	re := MustCompile(`(*CRLF)a(|b)(?C2)`)
+	re.Longest()
+	if loc := re.FindStringSubmatchIndex("xab"); !reflect.DeepEqual(loc, []int{1, 3, 2, 3}) {
+		t.Errorf("(*CRLF): FindStringSubmatchIndex %v", loc)
+	}
+}
+
+func TestMatchLimitPanics(t *testing.T) {
+	defer func() {
+		err, _ := recover().(error)
+		if !errors.Is(err, pcre.ErrMatchLimit) {
+			t.Errorf("recovered %v, want the match limit error", err)
+		}
+	}()
+	MustCompile(`(a+)+$`).MatchString(strings.Repeat("a", 40) + "b")
+	t.Error("no panic")
+}
+
+func TestMethodSet(t *testing.T) {
+	want := reflect.TypeOf(&regexp.Regexp{})
+	got := reflect.TypeOf(&Regexp{})
+	for i := 0; i < want.NumMethod(); i++ {
+		w := want.Method(i)
+		g, ok := got.MethodByName(w.Name)
+		if !ok {
+			t.Errorf("missing method %s", w.Name)
+		} else if g.Type.NumIn() != w.Type.NumIn() || g.Type.NumOut() != w.Type.NumOut() {
+			t.Errorf("method %s: %v, want %v", w.Name, g.Type, w.Type)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	re := MustCompile(`a+b`)
+	if b, err := re.AppendText([]byte("re:")); err != nil || string(b) != "re:a+b" {
+		t.Errorf("AppendText = %q, %v", b, err)
+	}
+	if b, err := re.MarshalText(); err != nil || string(b) != "a+b" {
+		t.Errorf("MarshalText = %q, %v", b, err)
+	}
+	var re2 Regexp
+	if err := re2.UnmarshalText([]byte(`x(y)`)); err != nil ||
+		re2.String() != `x(y)` || re2.NumSubexp() != 1 {
+		t.Errorf("UnmarshalText = %v, %v", re2.String(), err)
+	}
+}
+
+func TestQuoteMeta(t *testing.T) {
+	s := `1.5-2.0?[a]{b}(c)|d^e$f\g*h+`
+	if q := QuoteMeta(s); q != regexp.QuoteMeta(s) {
+		t.Error("QuoteMeta", q)
+	}
+	if !MustCompile(QuoteMeta(s)).MatchString("x" + s) {
+		t.Error("QuoteMeta does not match itself")
+	}
+}
+
+func TestLiteralPrefix(t *testing.T) {
+	var check = func(pattern, prefix string, complete bool) {
+		p, c := MustCompile(pattern).LiteralPrefix()
+		if p != prefix || c != complete {
+			t.Errorf("%s: LiteralPrefix %q %v", pattern, p, c)
+		}
+	}
+	check(`abc`, "abc", true)
+	check(`abc+`, "abc", false)
+	check(`abc*`, "ab", false)
+	check(`ab(c)`, "ab", false)
+	check(`a.c`, "a", false)
+}
@@ -0,0 +1,74 @@
+package pcre
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// hammer runs f from many goroutines at once.  Run with -race.
+func hammer(t *testing.T, f func()) {
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				f()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestConcurrentRegexp(t *testing.T) {
+	re := MustCompileJIT(`(\w+)@(\w+)\.com`, 0, STUDY_JIT_COMPILE)
+	defer re.Close()
+	subject := "mail bob@example.com and amy@test.com now"
+	wantAll := [][]string{
+		{"bob@example.com", "bob", "example"},
+		{"amy@test.com", "amy", "test"},
+	}
+	hammer(t, func() {
+		if got := re.FindAllStringSubmatch(subject, -1, 0); !reflect.DeepEqual(got, wantAll) {
+			t.Error("FindAllStringSubmatch", got)
+		}
+		if got := re.FindStringSubmatch(subject, 0); !reflect.DeepEqual(got, wantAll[0]) {
+			t.Error("FindStringSubmatch", got)
+		}
+		if got := re.FindIndex([]byte(subject), 0); !reflect.DeepEqual(got, []int{5, 20}) {
+			t.Error("FindIndex", got)
+		}
+		if got, err := re.ReplaceAllString(subject, "x", 0); err != nil ||
+			got != "mail x and x now" {
+			t.Error("ReplaceAllString", got, err)
+		}
+		if got, err := re.FindAll(subject, 0); err != nil || len(got) != 2 {
+			t.Error("FindAll", got, err)
+		}
+	})
+}
+
+func TestConcurrentRegexpSet(t *testing.T) {
+	s := MustCompileSet([]string{"num", "word"}, []string{`\d+`, `[a-z]+`}, 0)
+	defer s.Close()
+	hammer(t, func() {
+		got, err := s.FindAllString("ab 12 cd", -1, 0)
+		if err != nil || len(got) != 3 || got[1].ID != "num" {
+			t.Error("FindAllString", got, err)
+		}
+	})
+}
+
+func TestPooledMatcherAllocs(t *testing.T) {
+	re := MustCompile(`b+`, 0)
+	defer re.Close()
+	re.FindStringIndex("abbc", 0)
+	// Only the result is allocated; the Matcher comes from the pool.
+	allocs := testing.AllocsPerRun(100, func() {
+		re.FindStringIndex("abbc", 0)
+	})
+	if allocs > 1 {
+		t.Error("FindStringIndex allocations:", allocs)
+	}
+}
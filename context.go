@@ -0,0 +1,107 @@
+package pcre
+
+import (
+	"context"
+	"sync/atomic"
+	"unsafe"
+)
+
+// watchContext arranges for matches performed by m to be abandoned once
+// ctx is done, until stop is called.  A running match notices the
+// cancellation at its next callout if the pattern was compiled with
+// AUTO_CALLOUT or m has a callout function.  Other matches are run in
+// slices of growing match limits by exec, and notice it between them.
+// Matches started after cancellation fail at once.
+func (m *Matcher) watchContext(ctx context.Context) (stop func()) {
+	done := ctx.Done()
+	if done == nil {
+		return func() {}
+	}
+	watch := newCalloutData()
+	m.watch = watch
+	cancel := (*int32)(unsafe.Pointer(&watch.cancel))
+	stopped := make(chan struct{})
+	go func() {
+		select {
+		case <-done:
+			atomic.StoreInt32(cancel, 1)
+		case <-stopped:
+		}
+	}()
+	return func() {
+		close(stopped)
+		m.watch = nil
+	}
+}
+
+// contextError converts the error of a match abandoned by ctx into
+// a *MatchError wrapping ctx.Err().
+func contextError(ctx context.Context, err error) error {
+	if e, ok := err.(*MatchError); ok && e.Code == codeCanceled {
+		e.err = ctx.Err()
+	}
+	return err
+}
+
+// MatchContext is like Match, but abandons the match when ctx is done.
+// In that case it returns false and Err returns an error wrapping
+// ctx.Err().  A match of a pattern compiled with AUTO_CALLOUT stops at
+// its next item.  Other matches are restarted with growing match
+// limits, which at most doubles their work, and stop before a restart,
+// so that they run on for at most about as long as they had run when
+// ctx was done.  With a callout function set by SetCallout, which
+// should not see a match restart, they only stop at (?C) items.
+func (m *Matcher) MatchContext(ctx context.Context, subject []byte, flags int) bool {
+	return m.matchContext(ctx, func() bool {
+		return m.Match(subject, flags)
+	})
+}
+
+// MatchStringContext is like MatchString, but abandons the match when
+// ctx is done.  See MatchContext.
+func (m *Matcher) MatchStringContext(ctx context.Context, subject string, flags int) bool {
+	return m.matchContext(ctx, func() bool {
+		return m.MatchString(subject, flags)
+	})
+}
+
+func (m *Matcher) matchContext(ctx context.Context, match func() bool) bool {
+	if m.err != nil {
+		return false
+	}
+	if err := ctx.Err(); err != nil {
+		m.matches = false
+		m.err = contextError(ctx, newMatchError(codeCanceled, nil))
+		return false
+	}
+	defer m.watchContext(ctx)()
+	ok := match()
+	m.err = contextError(ctx, m.err)
+	return ok
+}
+
+// FindAllContext is like FindAll, but stops when ctx is done and then
+// returns an error wrapping ctx.Err().  See MatchContext.
+func (re Regexp) FindAllContext(ctx context.Context, subject string, flags int) ([]Match, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(ctx, newMatchError(codeCanceled, nil))
+	}
+	m := re.getMatcher()
+	defer re.putMatcher(m)
+	defer m.watchContext(ctx)()
+	matches, err := m.findAll(subject, flags)
+	return matches, contextError(ctx, err)
+}
+
+// ReplaceAllContext is like ReplaceAll, but stops when ctx is done and
+// then returns an error wrapping ctx.Err().  See MatchContext.
+func (re Regexp) ReplaceAllContext(ctx context.Context, bytes, repl []byte, flags int) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(ctx, newMatchError(codeCanceled, nil))
+	}
+	m := re.getMatcher()
+	defer re.putMatcher(m)
+	defer m.watchContext(ctx)()
+	r, err := m.replaceAll(bytes, repl, flags)
+	return r, contextError(ctx, err)
+}
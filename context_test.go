@@ -0,0 +1,73 @@
+package pcre
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMatchContext(t *testing.T) {
+	for _, flags := range []int{AUTO_CALLOUT, 0} {
+		re := MustCompile(`(a|aa)+$`, flags)
+		re.SetMatchLimit(1 << 30)
+		subject := string(bytes.Repeat([]byte("a"), 50)) + "b"
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		m := re.NewMatcher()
+		start := time.Now()
+		if m.MatchStringContext(ctx, subject, 0) {
+			t.Error("unexpected match")
+		}
+		if !errors.Is(m.Err(), context.DeadlineExceeded) {
+			t.Errorf("flags %#x: Err %v", flags, m.Err())
+		}
+		if d := time.Since(start); d > 5*time.Second {
+			t.Errorf("flags %#x: match was not interrupted after %v", flags, d)
+		}
+		cancel()
+		re.FreeRegexp()
+	}
+
+	// A watched match keeps to the match limit.
+	re := MustCompile(`(a|aa)+$`, 0)
+	defer re.FreeRegexp()
+	re.SetMatchLimit(watchedMatchLimit * 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m := re.NewMatcher()
+	subject := string(bytes.Repeat([]byte("a"), 50)) + "b"
+	if m.MatchStringContext(ctx, subject, 0) ||
+		!errors.Is(m.Err(), ErrMatchLimit) {
+		t.Error("match limit", m.Err())
+	}
+
+	// The context does not affect unrelated matches.
+	m = re.NewMatcher()
+	if !m.MatchContext(context.Background(), []byte("aaaa"), 0) || m.Err() != nil {
+		t.Error("MatchContext", m.Err())
+	}
+}
+
+func TestFindAllContext(t *testing.T) {
+	re := MustCompile(`\d`, 0)
+	defer re.FreeRegexp()
+	matches, err := re.FindAllContext(context.Background(), "1a2b3", 0)
+	if err != nil || len(matches) != 3 {
+		t.Error("FindAllContext", matches, err)
+	}
+	r, err := re.ReplaceAllContext(context.Background(), []byte("1a2"), []byte("#"), 0)
+	if err != nil || string(r) != "#a#" {
+		t.Error("ReplaceAllContext", string(r), err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := re.FindAllContext(ctx, "1a2b3", 0); !errors.Is(err, context.Canceled) {
+		t.Error("FindAllContext canceled", err)
+	}
+	if _, err := re.ReplaceAllContext(ctx, []byte("1"), nil, 0); !errors.Is(err, context.Canceled) {
+		t.Error("ReplaceAllContext canceled", err)
+	}
+}
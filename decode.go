@@ -0,0 +1,158 @@
+package pcre
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// NamedMap returns the values of the named capture groups set by the
+// last match, keyed by name.  Groups that are not set are left out.
+// NamedMap returns nil if the last match failed.
+func (m *Matcher) NamedMap() map[string]string {
+	if !m.matches {
+		return nil
+	}
+	values := make(map[string]string)
+	for name, groups := range m.re.SubexpIndices() {
+		for _, group := range groups {
+			if m.Present(group) {
+				values[name] = m.GroupString(group)
+				break
+			}
+		}
+	}
+	return values
+}
+
+// DecodeError reports a named group whose value could not be stored
+// in a struct field by Decode.
+type DecodeError struct {
+	Group string // Name of the capture group
+	Field string // Name of the struct field
+	Value string // Value of the capture group
+	Err   error  // Conversion error
+}
+
+// Error converts a decode error to a string.
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("pcre: cannot decode group %q value %q into field %s: %v",
+		e.Group, e.Value, e.Field, e.Err)
+}
+
+// Unwrap returns the conversion error.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	errNoMatch     = errors.New("pcre: Decode without a match")
+	errUnsupported = errors.New("unsupported field type")
+	durationType   = reflect.TypeOf(time.Duration(0))
+	timeType       = reflect.TypeOf(time.Time{})
+	textType       = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Decode stores the named capture groups set by the last match in the
+// fields of the struct that dst points to.  A field tagged `pcre:"name"`
+// receives the value of the group called name; fields of unset groups
+// are left unchanged.  Strings, bools, integers, floats, time.Duration
+// values, types implementing encoding.TextUnmarshaler and pointers to
+// these are converted from the group's text.  time.Time fields are
+// parsed with the layout in their `layout:"..."` tag, or time.RFC3339.
+// Conversion failures are reported as a *DecodeError.
+func (m *Matcher) Decode(dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("pcre: Decode needs a pointer to a struct, not %T", dst)
+	}
+	if !m.matches {
+		return errNoMatch
+	}
+	v = v.Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, ok := f.Tag.Lookup("pcre")
+		if !ok || name == "-" || f.PkgPath != "" {
+			continue
+		}
+		if ok, err := m.NamedPresent(name); err != nil {
+			return err
+		} else if !ok {
+			continue
+		}
+		s, _ := m.NamedString(name)
+		if err := decodeValue(v.Field(i), s, f.Tag.Get("layout")); err != nil {
+			return &DecodeError{Group: name, Field: f.Name, Value: s, Err: err}
+		}
+	}
+	return nil
+}
+
+// decodeValue converts s and stores it in v.
+func decodeValue(v reflect.Value, s, layout string) error {
+	if v.Kind() == reflect.Ptr {
+		p := reflect.New(v.Type().Elem())
+		if err := decodeValue(p.Elem(), s, layout); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	}
+	switch t := v.Type(); {
+	case t == timeType:
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		tm, err := time.Parse(layout, s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(tm))
+		return nil
+	case t == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	case reflect.PtrTo(t).Implements(textType):
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		x, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(x)
+	default:
+		return errUnsupported
+	}
+	return nil
+}
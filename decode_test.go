@@ -0,0 +1,77 @@
+package pcre
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var logLine = MustCompile(`^(?<ts>\S+) (?<ip>\S+) (?<status>\d+) `+
+	`(?<bytes>\d+) (?<secs>[\d.]+) (?<took>\S+) (?<cached>\w+)(?: (?<user>\w+))?$`, 0)
+
+func TestNamedMap(t *testing.T) {
+	m := logLine.MatcherString("2024-05-17 10.0.0.1 200 512 0.25 3ms true", 0)
+	want := map[string]string{
+		"ts": "2024-05-17", "ip": "10.0.0.1", "status": "200",
+		"bytes": "512", "secs": "0.25", "took": "3ms", "cached": "true",
+	}
+	if got := m.NamedMap(); !reflect.DeepEqual(got, want) {
+		t.Error("NamedMap", got)
+	}
+	if m.MatchString("nope", 0); m.NamedMap() != nil {
+		t.Error("NamedMap after failed match")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	type record struct {
+		Time    time.Time     `pcre:"ts" layout:"2006-01-02"`
+		IP      net.IP        `pcre:"ip"`
+		Status  int           `pcre:"status"`
+		Bytes   *uint32       `pcre:"bytes"`
+		Seconds float64       `pcre:"secs"`
+		Took    time.Duration `pcre:"took"`
+		Cached  bool          `pcre:"cached"`
+		User    string        `pcre:"user"`
+		Other   string
+	}
+	m := logLine.MatcherString("2024-05-17 10.0.0.1 200 512 0.25 3ms true", 0)
+	r := record{User: "unchanged", Other: "unchanged"}
+	if err := m.Decode(&r); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Time.Equal(time.Date(2024, 5, 17, 0, 0, 0, 0, time.UTC)) ||
+		!r.IP.Equal(net.IPv4(10, 0, 0, 1)) || r.Status != 200 ||
+		r.Bytes == nil || *r.Bytes != 512 || r.Seconds != 0.25 ||
+		r.Took != 3*time.Millisecond || !r.Cached ||
+		r.User != "unchanged" || r.Other != "unchanged" {
+		t.Error("Decode", r)
+	}
+
+	var small struct {
+		Status int8 `pcre:"status"`
+	}
+	err := m.Decode(&small)
+	var de *DecodeError
+	if !errors.As(err, &de) || de.Group != "status" || de.Field != "Status" ||
+		de.Value != "200" || !errors.Is(err, strconv.ErrRange) {
+		t.Error("DecodeError", err)
+	}
+
+	var unknown struct {
+		X string `pcre:"nosuchgroup"`
+	}
+	if err := m.Decode(&unknown); err == nil {
+		t.Error("unknown group")
+	}
+	if err := m.Decode(r); err == nil {
+		t.Error("non-pointer")
+	}
+	m.MatchString("nope", 0)
+	if err := m.Decode(&r); err == nil {
+		t.Error("Decode after failed match")
+	}
+}
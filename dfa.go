@@ -0,0 +1,212 @@
+package pcre
+
+// #include "./pcre.h"
+import "C"
+
+import (
+	"unsafe"
+)
+
+// Flags for DFAMatcher match functions
+const (
+	DFA_SHORTEST = C.PCRE_DFA_SHORTEST
+	DFA_RESTART  = C.PCRE_DFA_RESTART
+)
+
+// Initial and maximum sizes, in ints, of the DFA workspace.
+const (
+	dfaWorkspaceSize    = 1000
+	dfaMaxWorkspaceSize = 1 << 24
+)
+
+// DFAMatcher objects match with the alternative algorithm of
+// pcre_dfa_exec.  It scans the subject once, without backtracking, and
+// finds all the matches that start at the leftmost matching position.
+// Capture groups are not available in this mode.  A DFAMatcher owns the
+// workspace that DFA_RESTART needs to continue a partial match in the
+// next segment of the subject.
+type DFAMatcher struct {
+	re        Regexp
+	ovector   []C.int // pairs of match offsets, longest match first
+	workspace []C.int
+	count     int  // number of matches found by the last match
+	partial   bool // was the last match a partial match?
+	subjects  string
+	subjectb  []byte
+	err       error
+}
+
+// NewDFAMatcher creates a new DFA matcher object for the given Regexp.
+func (re Regexp) NewDFAMatcher() *DFAMatcher {
+	if re.c == nil {
+		panic("Regexp.NewDFAMatcher: uninitialized")
+	}
+	return &DFAMatcher{
+		re:        re,
+		ovector:   make([]C.int, 2*10),
+		workspace: make([]C.int, dfaWorkspaceSize),
+	}
+}
+
+// Err returns first error encountered by DFAMatcher.
+func (m *DFAMatcher) Err() error {
+	return m.err
+}
+
+// Match tries to match the specified byte slice to the current pattern.
+// Returns true if the match succeeds.  Flags may include DFA_SHORTEST,
+// to stop at the shortest match, and PARTIAL_SOFT or PARTIAL_HARD.
+// After a partial match with PARTIAL_HARD, pass the next segment of the
+// subject with DFA_RESTART to continue the match.
+// Match is a no-op if err is not nil.
+func (m *DFAMatcher) Match(subject []byte, flags int) bool {
+	return m.MatchFrom(subject, 0, flags)
+}
+
+// MatchString is like Match, with a string subject.
+func (m *DFAMatcher) MatchString(subject string, flags int) bool {
+	return m.MatchStringFrom(subject, 0, flags)
+}
+
+// MatchFrom is like Match, but the search starts at byte offset start
+// within subject.
+func (m *DFAMatcher) MatchFrom(subject []byte, start, flags int) bool {
+	if m.err != nil {
+		return false
+	}
+	rc := m.ExecFrom(subject, start, flags)
+	m.collect(rc)
+	return m.count > 0
+}
+
+// MatchStringFrom is like MatchString, but the search starts at byte
+// offset start within subject.
+func (m *DFAMatcher) MatchStringFrom(subject string, start, flags int) bool {
+	if m.err != nil {
+		return false
+	}
+	rc := m.ExecStringFrom(subject, start, flags)
+	m.collect(rc)
+	return m.count > 0
+}
+
+// collect records the outcome of a call to pcre_dfa_exec.
+func (m *DFAMatcher) collect(rc int) {
+	m.partial = (rc == ERROR_PARTIAL)
+	m.count = 0
+	var ok bool
+	ok, m.err = matched(rc, m.ovector)
+	switch {
+	case m.partial:
+		m.count = 1
+	case ok:
+		m.count = rc
+	}
+}
+
+// ExecFrom matches the specified byte slice, starting at byte offset
+// start, and returns the raw pcre_dfa_exec error code.  The offset
+// vector and workspace are grown as needed, except with DFA_RESTART,
+// which must see the workspace of the previous call.
+func (m *DFAMatcher) ExecFrom(subject []byte, start, flags int) int {
+	if m.re.c == nil {
+		panic("DFAMatcher.ExecFrom: uninitialized")
+	}
+	length := len(subject)
+	m.subjects = ""
+	m.subjectb = subject
+	if length == 0 {
+		subject = nullbyte // make first character addressable
+	}
+	subjectptr := (*C.char)(unsafe.Pointer(&subject[0]))
+	return m.exec(subjectptr, length, start, flags)
+}
+
+// ExecStringFrom is like ExecFrom, with a string subject.
+func (m *DFAMatcher) ExecStringFrom(subject string, start, flags int) int {
+	if m.re.c == nil {
+		panic("DFAMatcher.ExecStringFrom: uninitialized")
+	}
+	length := len(subject)
+	m.subjects = subject
+	m.subjectb = nil
+	if length == 0 {
+		subject = "\000" // make first character addressable
+	}
+	// The following is a non-portable kludge to avoid a copy
+	subjectptr := *(**C.char)(unsafe.Pointer(&subject))
+	return m.exec(subjectptr, length, start, flags)
+}
+
+func (m *DFAMatcher) exec(subjectptr *C.char, length, start, flags int) int {
+	if !m.re.c.acquire() {
+		return ERROR_NULL
+	}
+	defer m.re.c.release()
+	for {
+		rc := int(C.pcre_dfa_exec(m.re.c.ptr, m.re.c.extra,
+			subjectptr, C.int(length), C.int(start), C.int(flags),
+			&m.ovector[0], C.int(len(m.ovector)),
+			&m.workspace[0], C.int(len(m.workspace))))
+		switch {
+		case rc == 0:
+			// More matches were found than fit in the
+			// offset vector.
+			m.ovector = make([]C.int, 2*len(m.ovector))
+		case rc == C.PCRE_ERROR_DFA_WSSIZE && flags&DFA_RESTART == 0 &&
+			len(m.workspace) < dfaMaxWorkspaceSize:
+			m.workspace = make([]C.int, 2*len(m.workspace))
+		default:
+			return rc
+		}
+	}
+}
+
+// Matches returns true if the previous match succeeded.
+func (m *DFAMatcher) Matches() bool {
+	return m.count > 0
+}
+
+// Partial returns true if the previous match was a partial match.
+func (m *DFAMatcher) Partial() bool {
+	return m.partial
+}
+
+// Count returns the number of alternative matches found at the leftmost
+// matching position by the previous match.
+func (m *DFAMatcher) Count() int {
+	return m.count
+}
+
+// Index returns the start and end of the i'th match found by the
+// previous match, or nil if there is no such match.  Matches are sorted
+// longest first, so Index(0) is the longest match.
+func (m *DFAMatcher) Index(i int) []int {
+	if i < 0 || i >= m.count {
+		return nil
+	}
+	return []int{int(m.ovector[2*i]), int(m.ovector[2*i+1])}
+}
+
+// Lengths returns the lengths of all the matches found by the previous
+// match, longest first.
+func (m *DFAMatcher) Lengths() []int {
+	lengths := make([]int, m.count)
+	for i := range lengths {
+		lengths[i] = int(m.ovector[2*i+1] - m.ovector[2*i])
+	}
+	return lengths
+}
+
+// Text returns the text of the i'th match found by the previous
+// match, or an empty string if there is no such match.
+func (m *DFAMatcher) Text(i int) string {
+	loc := m.Index(i)
+	if loc == nil {
+		return ""
+	}
+	if m.subjectb != nil {
+		return string(m.subjectb[loc[0]:loc[1]])
+	}
+	return m.subjects[loc[0]:loc[1]]
+}
@@ -0,0 +1,72 @@
+package pcre
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDFAMatcher(t *testing.T) {
+	re := MustCompile(`<.*>`, 0)
+	defer re.FreeRegexp()
+	m := re.NewDFAMatcher()
+	if !m.MatchString("x<a><b>y", 0) {
+		t.Fatal("no match")
+	}
+	if m.Count() != 2 {
+		t.Error("Count", m.Count())
+	}
+	if l := m.Lengths(); !reflect.DeepEqual(l, []int{6, 3}) {
+		t.Error("Lengths", l)
+	}
+	if i := m.Index(0); !reflect.DeepEqual(i, []int{1, 7}) {
+		t.Error("Index", i)
+	}
+	if s := m.Text(1); s != "<a>" {
+		t.Error("Text", s)
+	}
+
+	if !m.Match([]byte("x<a><b>y"), DFA_SHORTEST) {
+		t.Fatal("no shortest match")
+	}
+	if l := m.Lengths(); !reflect.DeepEqual(l, []int{3}) {
+		t.Error("DFA_SHORTEST Lengths", l)
+	}
+
+	if m.MatchString("<a", 0) || m.Err() != nil {
+		t.Error("unexpected match", m.Err())
+	}
+}
+
+func TestDFAMatcherManyMatches(t *testing.T) {
+	re := MustCompile(`a+?`, 0)
+	defer re.FreeRegexp()
+	m := re.NewDFAMatcher()
+	subject := "baaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	if !m.MatchString(subject, 0) {
+		t.Fatal("no match")
+	}
+	if m.Count() != len(subject)-1 {
+		t.Error("Count", m.Count())
+	}
+}
+
+func TestDFARestart(t *testing.T) {
+	re := MustCompile(`abc\d+`, 0)
+	defer re.FreeRegexp()
+	m := re.NewDFAMatcher()
+	if !m.MatchString("xxab", PARTIAL_HARD) || !m.Partial() {
+		t.Fatal("expected partial match")
+	}
+	if i := m.Index(0); !reflect.DeepEqual(i, []int{2, 4}) {
+		t.Error("partial Index", i)
+	}
+	if !m.MatchString("c12", PARTIAL_HARD|DFA_RESTART) || !m.Partial() {
+		t.Fatal("expected partial match after restart")
+	}
+	if !m.MatchString("3;", DFA_RESTART) || m.Partial() {
+		t.Fatal("expected complete match after restart", m.Err())
+	}
+	if i := m.Index(0); !reflect.DeepEqual(i, []int{0, 1}) {
+		t.Error("restart Index", i)
+	}
+}
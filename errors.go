@@ -0,0 +1,195 @@
+package pcre
+
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Sentinel errors for the failures of a match.  The errors returned by
+// Matcher.Err and the functions built on it are *MatchError values,
+// which can be compared with these using errors.Is.
+var (
+	ErrBadOption      = errors.New("pcre: invalid option flag")
+	ErrNoMemory       = errors.New("pcre: out of memory")
+	ErrMatchLimit     = errors.New("pcre: match limit exceeded")
+	ErrCallout        = errors.New("pcre: match aborted by callout")
+	ErrBadUTF8        = errors.New("pcre: invalid UTF-8 subject")
+	ErrBadUTF8Offset  = errors.New("pcre: start offset inside a UTF-8 character")
+	ErrShortUTF8      = errors.New("pcre: truncated UTF-8 character at end of subject")
+	ErrBadPartial     = errors.New("pcre: pattern unsupported for partial matching")
+	ErrInternal       = errors.New("pcre: internal error")
+	ErrRecursionLimit = errors.New("pcre: recursion limit exceeded")
+	ErrRecurseLoop    = errors.New("pcre: recursion loop detected")
+	ErrBadOffset      = errors.New("pcre: start offset out of range")
+	ErrJITStackLimit  = errors.New("pcre: JIT stack limit exceeded")
+	ErrJITBadOption   = errors.New("pcre: match option unsupported by JIT-compiled pattern")
+	ErrDFAUnsupported = errors.New("pcre: pattern unsupported for DFA matching")
+	ErrDFARestart     = errors.New("pcre: DFA restart without previous partial match")
+)
+
+// sentinels maps pcre_exec and pcre_dfa_exec return codes to the
+// sentinel errors that a MatchError with that code matches.
+var sentinels = map[int]error{
+	ERROR_NULL:           ErrFreed,
+	ERROR_BADOPTION:      ErrBadOption,
+	ERROR_NOMEMORY:       ErrNoMemory,
+	ERROR_MATCHLIMIT:     ErrMatchLimit,
+	ERROR_CALLOUT:        ErrCallout,
+	ERROR_BADUTF8:        ErrBadUTF8,
+	ERROR_BADUTF8_OFFSET: ErrBadUTF8Offset,
+	ERROR_SHORTUTF8:      ErrShortUTF8,
+	ERROR_BADPARTIAL:     ErrBadPartial,
+	ERROR_INTERNAL:       ErrInternal,
+	ERROR_RECURSIONLIMIT: ErrRecursionLimit,
+	ERROR_RECURSELOOP:    ErrRecurseLoop,
+	ERROR_BADOFFSET:      ErrBadOffset,
+	ERROR_JIT_STACKLIMIT: ErrJITStackLimit,
+	ERROR_JIT_BADOPTION:  ErrJITBadOption,
+	ERROR_DFA_UITEM:      ErrDFAUnsupported,
+	ERROR_DFA_UCOND:      ErrDFAUnsupported,
+	ERROR_DFA_UMLIMIT:    ErrDFAUnsupported,
+	ERROR_DFA_BADRESTART: ErrDFARestart,
+}
+
+// matchMessages describes the return codes without a sentinel error.
+var matchMessages = map[int]string{
+	ERROR_BADMAGIC:       "bad magic number in compiled pattern",
+	ERROR_UNKNOWN_OPCODE: "unknown opcode in compiled pattern",
+	ERROR_NOSUBSTRING:    "no such substring",
+	ERROR_BADCOUNT:       "negative offset vector size",
+	ERROR_DFA_WSSIZE:     "DFA workspace too small",
+	ERROR_DFA_RECURSE:    "DFA recursion offset vector too small",
+	ERROR_BADNEWLINE:     "invalid combination of newline options",
+	ERROR_BADMODE:        "pattern compiled for another character width",
+	ERROR_BADENDIANNESS:  "pattern compiled with another endianness",
+	ERROR_BADLENGTH:      "negative subject length",
+	codeCanceled:         "match canceled",
+}
+
+// utf8Reasons describes the PCRE_UTF8_ERRn reason codes of bad UTF-8.
+var utf8Reasons = [...]string{
+	1:  "missing 1 byte at end of subject",
+	2:  "missing 2 bytes at end of subject",
+	3:  "missing 3 bytes at end of subject",
+	4:  "missing 4 bytes at end of subject",
+	5:  "missing 5 bytes at end of subject",
+	6:  "2nd byte of character is not a continuation byte",
+	7:  "3rd byte of character is not a continuation byte",
+	8:  "4th byte of character is not a continuation byte",
+	9:  "5th byte of character is not a continuation byte",
+	10: "6th byte of character is not a continuation byte",
+	11: "5-byte character is not allowed",
+	12: "6-byte character is not allowed",
+	13: "code point above 0x10ffff",
+	14: "surrogate code point 0xd800-0xdfff",
+	15: "overlong 2-byte sequence",
+	16: "overlong 3-byte sequence",
+	17: "overlong 4-byte sequence",
+	18: "overlong 5-byte sequence",
+	19: "overlong 6-byte sequence",
+	20: "isolated continuation byte",
+	21: "illegal byte 0xfe or 0xff",
+}
+
+// wideMessages describes the errors of bad UTF-16 and UTF-32 subjects.
+var wideMessages = map[int]string{
+	ERROR_BADUTF8:        "invalid UTF-%d subject",
+	ERROR_BADUTF8_OFFSET: "start offset inside a UTF-%d character",
+	ERROR_SHORTUTF8:      "truncated UTF-%d character at end of subject",
+}
+
+// utf16Reasons and utf32Reasons describe the PCRE_UTF16_ERRn and
+// PCRE_UTF32_ERRn reason codes of bad Matcher16 and Matcher32 subjects.
+var (
+	utf16Reasons = [...]string{
+		1: "missing low surrogate at end of subject",
+		2: "invalid low surrogate",
+		3: "isolated low surrogate",
+	}
+	utf32Reasons = [...]string{
+		1: "surrogate code point",
+		3: "code point above 0x10ffff",
+	}
+)
+
+// MatchError describes a failed match, as opposed to a match that
+// did not find anything.  Use errors.Is with the sentinel errors of
+// this package to test for specific failures.
+type MatchError struct {
+	Code    int    // Return code of pcre_exec or pcre_dfa_exec
+	Message string // Description of the error
+	// For ERROR_BADUTF8 and ERROR_SHORTUTF8, Offset is the byte
+	// offset of the invalid character and Reason is one of PCRE's
+	// PCRE_UTF8_ERRn codes.  Otherwise Offset is -1 and Reason is 0.
+	// For Matcher16 and Matcher32 the offset is in code units and the
+	// reason a PCRE_UTF16_ERRn or PCRE_UTF32_ERRn code.
+	Offset int
+	Reason int
+	width  int   // code unit width of the subject, 0 for bytes
+	err    error // underlying error, such as a context's error
+}
+
+// newMatchError returns the error for the return code rc.  ovector
+// holds the offsets set by the failed match.
+func newMatchError(rc int, ovector []C.int) *MatchError {
+	e := &MatchError{Code: rc, Offset: -1}
+	if sentinel, ok := sentinels[rc]; ok {
+		e.Message = sentinel.Error()[len("pcre: "):]
+	} else if msg, ok := matchMessages[rc]; ok {
+		e.Message = msg
+	} else {
+		e.Message = "unexpected return code " + strconv.Itoa(rc)
+	}
+	if (rc == ERROR_BADUTF8 || rc == ERROR_SHORTUTF8) && len(ovector) >= 2 {
+		e.Offset = int(ovector[0])
+		e.Reason = int(ovector[1])
+	}
+	return e
+}
+
+// setWidth marks e as the error of a match against a subject of 16 or
+// 32-bit code units.  PCRE reports bad UTF-16 and UTF-32 with the
+// codes of bad UTF-8, so these still match ErrBadUTF8 and friends.
+func (e *MatchError) setWidth(width int) {
+	e.width = width
+	if format, ok := wideMessages[e.Code]; ok {
+		e.Message = fmt.Sprintf(format, width)
+	}
+}
+
+// Error converts a match error to a string.
+func (e *MatchError) Error() string {
+	s := "pcre: " + e.Message
+	if e.Offset >= 0 {
+		s += " at offset " + strconv.Itoa(e.Offset)
+		reasons := utf8Reasons[:]
+		switch e.width {
+		case 16:
+			reasons = utf16Reasons[:]
+		case 32:
+			reasons = utf32Reasons[:]
+		}
+		if e.Reason > 0 && e.Reason < len(reasons) && reasons[e.Reason] != "" {
+			s += ": " + reasons[e.Reason]
+		}
+	}
+	if e.err != nil {
+		s += ": " + e.err.Error()
+	}
+	return s
+}
+
+// Is reports whether target is the sentinel error for the code of e.
+func (e *MatchError) Is(target error) bool {
+	sentinel, ok := sentinels[e.Code]
+	return ok && sentinel == target
+}
+
+// Unwrap returns the underlying error, such as the error of the
+// context that canceled the match.
+func (e *MatchError) Unwrap() error {
+	return e.err
+}
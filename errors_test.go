@@ -0,0 +1,53 @@
+package pcre
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMatchErrorBadUTF8(t *testing.T) {
+	re := MustCompile(`c`, UTF8)
+	defer re.FreeRegexp()
+	m := re.MatcherString("ab\xffc", 0)
+	if m.Matches() {
+		t.Fatal("matched invalid UTF-8")
+	}
+	var e *MatchError
+	if !errors.As(m.Err(), &e) {
+		t.Fatal("not a MatchError", m.Err())
+	}
+	if e.Code != ERROR_BADUTF8 || e.Offset != 2 || e.Reason != 21 {
+		t.Error("MatchError", e.Code, e.Offset, e.Reason)
+	}
+	if !errors.Is(m.Err(), ErrBadUTF8) || errors.Is(m.Err(), ErrMatchLimit) {
+		t.Error("errors.Is", m.Err())
+	}
+	const want = "pcre: invalid UTF-8 subject at offset 2: illegal byte 0xfe or 0xff"
+	if s := m.Err().Error(); s != want {
+		t.Error("Error", s)
+	}
+
+	m = re.MatcherString("a\xc3", 0)
+	if !errors.Is(m.Err(), ErrBadUTF8) {
+		t.Error("truncated character", m.Err())
+	}
+	if m = re.MatcherString("a\xc3", PARTIAL_HARD); !errors.Is(m.Err(), ErrShortUTF8) {
+		t.Error("truncated character with PARTIAL_HARD", m.Err())
+	}
+}
+
+func TestMatchErrorCode(t *testing.T) {
+	re := MustCompile(`a`, 0)
+	defer re.FreeRegexp()
+	m := re.NewMatcher()
+	if m.MatchStringFrom("abc", 10, 0) {
+		t.Fatal("matched past the end")
+	}
+	if !errors.Is(m.Err(), ErrBadOffset) {
+		t.Error("ErrBadOffset", m.Err())
+	}
+	var e *MatchError
+	if !errors.As(m.Err(), &e) || e.Code != ERROR_BADOFFSET || e.Offset != -1 {
+		t.Error("MatchError", m.Err())
+	}
+}
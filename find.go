@@ -0,0 +1,266 @@
+package pcre
+
+// The functions in this file mirror the Find family of Go's regexp
+// package.  Each takes the match flags as its last argument.  The All
+// variants return at most n matches, or all matches if n < 0, and follow
+// the standard library in ignoring an empty match that immediately
+// follows a previous match.
+
+// submatchIndex returns the offsets of the last match and of all its
+// capture groups, in the layout used by regexp.FindSubmatchIndex.
+// Groups which are not present have offsets -1.
+func (m *Matcher) submatchIndex() []int {
+	loc := make([]int, 2*(m.groups+1))
+	for i := range loc {
+		loc[i] = int(m.ovector[i])
+	}
+	return loc
+}
+
+// allIndex collects the submatch indices of up to n successive
+// non-overlapping matches.  match is called with the offset at which
+// the next search should start, and step with the offset of an empty
+// match, to return the offset of the next character.
+func (m *Matcher) allIndex(length, n int, match func(start int) bool, step func(pos int) int) [][]int {
+	if n < 0 {
+		n = length + 1
+	}
+	var result [][]int
+	for pos, prevMatchEnd := 0, -1; len(result) < n && pos <= length; {
+		if !match(pos) {
+			break
+		}
+		loc := m.submatchIndex()
+		accept := true
+		if loc[1] == pos {
+			// An empty match right after the previous match is
+			// skipped; always advance to make progress.
+			if loc[0] == prevMatchEnd {
+				accept = false
+			}
+			pos = step(pos)
+		} else {
+			pos = loc[1]
+		}
+		prevMatchEnd = loc[1]
+		if accept {
+			result = append(result, loc)
+		}
+	}
+	return result
+}
+
+func (re Regexp) allIndexBytes(b []byte, n, flags int) [][]int {
+	m := re.getMatcher()
+	defer re.putMatcher(m)
+	st := re.stepper(flags)
+	checked := false // a search has found b valid
+	return m.allIndex(len(b), n, func(start int) bool {
+		f := flags
+		if checked {
+			f |= st.noCheck(b, "", start)
+		}
+		checked = m.MatchFrom(b, start, f)
+		return checked
+	}, func(pos int) int {
+		return st.step(b, "", pos)
+	})
+}
+
+func (re Regexp) allIndexString(s string, n, flags int) [][]int {
+	m := re.getMatcher()
+	defer re.putMatcher(m)
+	st := re.stepper(flags)
+	checked := false // a search has found s valid
+	return m.allIndex(len(s), n, func(start int) bool {
+		f := flags
+		if checked {
+			f |= st.noCheck(nil, s, start)
+		}
+		checked = m.MatchStringFrom(s, start, f)
+		return checked
+	}, func(pos int) int {
+		return st.step(nil, s, pos)
+	})
+}
+
+// Find returns a slice holding the text of the leftmost match in b,
+// or nil if there is no match.
+func (re Regexp) Find(b []byte, flags int) []byte {
+	m := re.getMatcher()
+	defer re.putMatcher(m)
+	if !m.Match(b, flags) {
+		return nil
+	}
+	return b[m.ovector[0]:m.ovector[1]:m.ovector[1]]
+}
+
+// FindString returns the text of the leftmost match in s.  If there
+// is no match, the return value is an empty string, but it will also
+// be empty if the pattern matches an empty string.  Use FindStringIndex
+// or FindStringSubmatch to distinguish the two cases.
+func (re Regexp) FindString(s string, flags int) string {
+	m := re.getMatcher()
+	defer re.putMatcher(m)
+	if !m.MatchString(s, flags) {
+		return ""
+	}
+	return s[m.ovector[0]:m.ovector[1]]
+}
+
+// FindStringIndex returns the start and end of the leftmost match in s,
+// or nil if no match.  loc[0] is the start and loc[1] is the end.
+func (re Regexp) FindStringIndex(s string, flags int) (loc []int) {
+	m := re.getMatcher()
+	defer re.putMatcher(m)
+	if !m.MatchString(s, flags) {
+		return nil
+	}
+	return []int{int(m.ovector[0]), int(m.ovector[1])}
+}
+
+// FindSubmatch returns a slice holding the text of the leftmost match
+// in b and of its capture groups.  Groups which are not present are nil.
+// A nil return value indicates no match.
+func (re Regexp) FindSubmatch(b []byte, flags int) [][]byte {
+	m := re.getMatcher()
+	defer re.putMatcher(m)
+	if !m.Match(b, flags) {
+		return nil
+	}
+	return bytesFromIndex(b, m.submatchIndex())
+}
+
+// FindSubmatchIndex returns the index pairs of the leftmost match in b
+// and of its capture groups.  Groups which are not present have index -1.
+// A nil return value indicates no match.
+func (re Regexp) FindSubmatchIndex(b []byte, flags int) []int {
+	m := re.getMatcher()
+	defer re.putMatcher(m)
+	if !m.Match(b, flags) {
+		return nil
+	}
+	return m.submatchIndex()
+}
+
+// FindStringSubmatch returns a slice holding the text of the leftmost
+// match in s and of its capture groups.  Groups which are not present
+// are empty strings.  A nil return value indicates no match.
+func (re Regexp) FindStringSubmatch(s string, flags int) []string {
+	m := re.getMatcher()
+	defer re.putMatcher(m)
+	if !m.MatchString(s, flags) {
+		return nil
+	}
+	return stringsFromIndex(s, m.submatchIndex())
+}
+
+// FindStringSubmatchIndex returns the index pairs of the leftmost match
+// in s and of its capture groups.  Groups which are not present have
+// index -1.  A nil return value indicates no match.
+func (re Regexp) FindStringSubmatchIndex(s string, flags int) []int {
+	m := re.getMatcher()
+	defer re.putMatcher(m)
+	if !m.MatchString(s, flags) {
+		return nil
+	}
+	return m.submatchIndex()
+}
+
+// FindAllIndex returns the start and end of successive matches in b.
+// A nil return value indicates no match.
+func (re Regexp) FindAllIndex(b []byte, n, flags int) [][]int {
+	return wholeMatches(re.allIndexBytes(b, n, flags))
+}
+
+// FindAllString returns the text of successive matches in s.
+// A nil return value indicates no match.
+func (re Regexp) FindAllString(s string, n, flags int) []string {
+	all := re.allIndexString(s, n, flags)
+	if all == nil {
+		return nil
+	}
+	result := make([]string, len(all))
+	for i, loc := range all {
+		result[i] = s[loc[0]:loc[1]]
+	}
+	return result
+}
+
+// FindAllStringIndex returns the start and end of successive matches
+// in s.  A nil return value indicates no match.
+func (re Regexp) FindAllStringIndex(s string, n, flags int) [][]int {
+	return wholeMatches(re.allIndexString(s, n, flags))
+}
+
+// FindAllSubmatch returns the text of successive matches in b and of
+// their capture groups, as FindSubmatch does for a single match.
+// A nil return value indicates no match.
+func (re Regexp) FindAllSubmatch(b []byte, n, flags int) [][][]byte {
+	all := re.allIndexBytes(b, n, flags)
+	if all == nil {
+		return nil
+	}
+	result := make([][][]byte, len(all))
+	for i, loc := range all {
+		result[i] = bytesFromIndex(b, loc)
+	}
+	return result
+}
+
+// FindAllSubmatchIndex returns the index pairs of successive matches
+// in b and of their capture groups.  A nil return value indicates
+// no match.
+func (re Regexp) FindAllSubmatchIndex(b []byte, n, flags int) [][]int {
+	return re.allIndexBytes(b, n, flags)
+}
+
+// FindAllStringSubmatch returns the text of successive matches in s
+// and of their capture groups, as FindStringSubmatch does for a single
+// match.  A nil return value indicates no match.
+func (re Regexp) FindAllStringSubmatch(s string, n, flags int) [][]string {
+	all := re.allIndexString(s, n, flags)
+	if all == nil {
+		return nil
+	}
+	result := make([][]string, len(all))
+	for i, loc := range all {
+		result[i] = stringsFromIndex(s, loc)
+	}
+	return result
+}
+
+// FindAllStringSubmatchIndex returns the index pairs of successive
+// matches in s and of their capture groups.  A nil return value
+// indicates no match.
+func (re Regexp) FindAllStringSubmatchIndex(s string, n, flags int) [][]int {
+	return re.allIndexString(s, n, flags)
+}
+
+// wholeMatches truncates submatch indices to the whole match.
+func wholeMatches(all [][]int) [][]int {
+	for i, loc := range all {
+		all[i] = loc[:2]
+	}
+	return all
+}
+
+func bytesFromIndex(b []byte, loc []int) [][]byte {
+	result := make([][]byte, len(loc)/2)
+	for i := range result {
+		if loc[2*i] >= 0 {
+			result[i] = b[loc[2*i]:loc[2*i+1]:loc[2*i+1]]
+		}
+	}
+	return result
+}
+
+func stringsFromIndex(s string, loc []int) []string {
+	result := make([]string, len(loc)/2)
+	for i := range result {
+		if loc[2*i] >= 0 {
+			result[i] = s[loc[2*i]:loc[2*i+1]]
+		}
+	}
+	return result
+}
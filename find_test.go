@@ -0,0 +1,148 @@
+package pcre
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	re := MustCompile(`(a)(x)?(\d+)`, 0)
+	defer re.FreeRegexp()
+	subject := "-a12-ax3-"
+	if f := re.Find([]byte(subject), 0); string(f) != "a12" {
+		t.Error("Find", string(f))
+	}
+	if f := re.FindString(subject, 0); f != "a12" {
+		t.Error("FindString", f)
+	}
+	if f := re.FindStringIndex(subject, 0); !reflect.DeepEqual(f, []int{1, 4}) {
+		t.Error("FindStringIndex", f)
+	}
+	if f := re.FindSubmatch([]byte(subject), 0); f[2] != nil ||
+		!reflect.DeepEqual(strings(f), []string{"a12", "a", "", "12"}) {
+		t.Error("FindSubmatch", strings(f))
+	}
+	if f := re.FindStringSubmatch(subject, 0); !reflect.DeepEqual(f,
+		[]string{"a12", "a", "", "12"}) {
+		t.Error("FindStringSubmatch", f)
+	}
+	if f := re.FindSubmatchIndex([]byte(subject), 0); !reflect.DeepEqual(f,
+		[]int{1, 4, 1, 2, -1, -1, 2, 4}) {
+		t.Error("FindSubmatchIndex", f)
+	}
+	if f := re.Find([]byte("nothing"), 0); f != nil {
+		t.Error("Find on no match", f)
+	}
+	if f := re.FindStringSubmatch("nothing", 0); f != nil {
+		t.Error("FindStringSubmatch on no match", f)
+	}
+}
+
+func TestFindAllN(t *testing.T) {
+	re := MustCompile(`(a)(x)?(\d+)`, 0)
+	defer re.FreeRegexp()
+	subject := "-a12-ax3-a4"
+	if f := re.FindAllString(subject, -1, 0); !reflect.DeepEqual(f,
+		[]string{"a12", "ax3", "a4"}) {
+		t.Error("FindAllString", f)
+	}
+	if f := re.FindAllStringIndex(subject, 2, 0); !reflect.DeepEqual(f,
+		[][]int{{1, 4}, {5, 8}}) {
+		t.Error("FindAllStringIndex", f)
+	}
+	if f := re.FindAllStringSubmatchIndex(subject, -1, 0); !reflect.DeepEqual(f,
+		[][]int{
+			{1, 4, 1, 2, -1, -1, 2, 4},
+			{5, 8, 5, 6, 6, 7, 7, 8},
+			{9, 11, 9, 10, -1, -1, 10, 11},
+		}) {
+		t.Error("FindAllStringSubmatchIndex", f)
+	}
+	if f := re.FindAllSubmatch([]byte(subject), 1, 0); len(f) != 1 ||
+		!reflect.DeepEqual(strings(f[0]), []string{"a12", "a", "", "12"}) {
+		t.Error("FindAllSubmatch", f)
+	}
+	if f := re.FindAllString(subject, 0, 0); f != nil {
+		t.Error("FindAllString n=0", f)
+	}
+	if f := re.FindAllString("nothing", -1, 0); f != nil {
+		t.Error("FindAllString on no match", f)
+	}
+}
+
+func TestFindAllEmpty(t *testing.T) {
+	// Same results as the standard library's regexp package.
+	var check = func(pattern, subject string, expected []string) {
+		re := MustCompile(pattern, 0)
+		defer re.FreeRegexp()
+		if f := re.FindAllString(subject, -1, 0); !reflect.DeepEqual(f, expected) {
+			t.Errorf("%s on %q: %q", pattern, subject, f)
+		}
+	}
+	check(`a*`, "baaab", []string{"", "aaa", ""})
+	check(`\w*`, "cat dog", []string{"cat", "dog"})
+	check(`x*`, "", []string{""})
+	check(`(?<=a)b`, "abab", []string{"b", "b"})
+}
+
+func TestFindAllEmptyAdvance(t *testing.T) {
+	var check = func(pattern string, flags int, subject string, expected [][]int) {
+		re := MustCompile(pattern, flags)
+		defer re.FreeRegexp()
+		if f := re.FindAllStringIndex(subject, -1, 0); !reflect.DeepEqual(f, expected) {
+			t.Errorf("%s on %q: %v, want %v", pattern, subject, f, expected)
+		}
+		if f := re.FindAllIndex([]byte(subject), -1, 0); !reflect.DeepEqual(f, expected) {
+			t.Errorf("%s on []byte(%q): %v, want %v", pattern, subject, f, expected)
+		}
+	}
+	// Empty matches advance by whole characters in UTF-8 mode,
+	check(`x*`, UTF8, "éx€", [][]int{{0, 0}, {2, 3}, {6, 6}})
+	check(`(*UTF8)`, 0, "é", [][]int{{0, 0}, {2, 2}})
+	// and over CRLF when it is a newline.
+	check(`x*`, NEWLINE_CRLF, "a\r\n", [][]int{{0, 0}, {1, 1}, {3, 3}})
+	check(`(*ANY)x*`, 0, "\r\n", [][]int{{0, 0}, {2, 2}})
+	check(`x*`, 0, "\r\n", [][]int{{0, 0}, {1, 1}, {2, 2}})
+}
+
+func TestReplaceAllEmptyAdvance(t *testing.T) {
+	re := MustCompile(`x*`, UTF8|NEWLINE_CRLF)
+	defer re.FreeRegexp()
+	result, err := re.ReplaceAllString("é\r\nx€", "-", 0)
+	if err != nil || result != "-é-\r\n-€-" {
+		t.Errorf("ReplaceAllString = %q, %v", result, err)
+	}
+	matches, err := re.FindAll("éé", 0)
+	if err != nil || len(matches) != 2 || matches[1].Loc[0] != 2 {
+		t.Errorf("FindAll = %v, %v", matches, err)
+	}
+	set := MustCompileSet([]string{"x"}, []string{`x*`}, UTF8)
+	defer set.Close()
+	if got, err := set.FindAllString("éé", -1, 0); err != nil || len(got) != 3 {
+		t.Errorf("RegexpSet.FindAllString = %v, %v", got, err)
+	}
+}
+
+func TestFindAllUTF8Check(t *testing.T) {
+	// Only the first search checks the subject, which is still
+	// rejected if invalid.
+	re := MustCompile(`b`, UTF8)
+	defer re.FreeRegexp()
+	if got := re.FindAllStringIndex("ab ab ab", -1, 0); len(got) != 3 {
+		t.Errorf("FindAllStringIndex = %v", got)
+	}
+	if _, err := re.ReplaceAllString("ab\xff", "x", 0); !errors.Is(err, ErrBadUTF8) {
+		t.Errorf("ReplaceAllString of invalid UTF-8: %v", err)
+	}
+	// A search starting inside a character, after a match of \C,
+	// is checked and fails rather than reading a partial character.
+	re = MustCompile(`\C`, UTF8)
+	defer re.FreeRegexp()
+	if got := re.FindAllStringIndex("é", -1, 0); !reflect.DeepEqual(got, [][]int{{0, 1}}) {
+		t.Errorf("FindAllStringIndex(\\C) = %v", got)
+	}
+	if _, err := re.ReplaceAllString("é", "x", 0); !errors.Is(err, ErrBadUTF8Offset) {
+		t.Errorf("ReplaceAllString(\\C): %v", err)
+	}
+}
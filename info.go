@@ -0,0 +1,106 @@
+package pcre
+
+// #include <stdint.h>
+// #include "./pcre.h"
+import "C"
+
+import (
+	"unsafe"
+)
+
+// Info describes a compiled pattern, as reported by pcre_fullinfo.
+type Info struct {
+	Options    int // Compile options, including those set at the start of the pattern
+	Size       int // Size of the compiled pattern in bytes
+	Groups     int // Number of capture groups
+	BackrefMax int // Highest back reference, 0 if none
+	// FirstCharacterFlags is 1 if every match starts with
+	// FirstCharacter, 2 if matches start at the start of the subject
+	// or after a newline, and 0 otherwise.
+	FirstCharacter      rune
+	FirstCharacterFlags int
+	// RequiredCharacterFlags is 1 if every match contains
+	// RequiredCharacter, the last fixed character of the pattern, and
+	// 0 otherwise.
+	RequiredCharacter      rune
+	RequiredCharacterFlags int
+	MinLength              int  // Minimum subject length matched, -1 if not studied
+	MaxLookbehind          int  // Longest lookbehind in characters
+	JIT                    bool // Pattern was JIT-compiled by Study
+	JITSize                int  // Size of the JIT-compiled code in bytes
+	StudySize              int  // Size of the study data in bytes
+	HasCRorLF              bool // Pattern contains an explicit CR or LF
+	JChanged               bool // Pattern sets the J option with (?J)
+	MatchEmpty             bool // Pattern can match an empty string
+	// Limits set by (*LIMIT_MATCH=n) and (*LIMIT_RECURSION=n) at the
+	// start of the pattern, 0 if unset.
+	MatchLimit     int
+	RecursionLimit int
+}
+
+// Info returns information about the compiled pattern.  It returns
+// ErrFreed if the pattern has been freed.
+func (re Regexp) Info() (Info, error) {
+	if re.c == nil {
+		panic("Regexp.Info: uninitialized")
+	}
+	if !re.c.acquire() {
+		return Info{}, ErrFreed
+	}
+	defer re.c.release()
+	c := re.c
+	fullinfo := func(what C.int, where unsafe.Pointer) bool {
+		return C.pcre_fullinfo(c.ptr, c.extra, what, where) == 0
+	}
+	var (
+		options                    C.ulong
+		size, jitSize, studySize   C.size_t
+		backrefMax, minLength      C.int
+		maxLookbehind, jit         C.int
+		firstFlags, requiredFlags  C.int
+		first, required            C.uint32_t
+		hasCRorLF, jChanged, empty C.int
+		matchLimit, recursionLimit C.uint32_t
+	)
+	fullinfo(C.PCRE_INFO_OPTIONS, unsafe.Pointer(&options))
+	fullinfo(C.PCRE_INFO_SIZE, unsafe.Pointer(&size))
+	fullinfo(C.PCRE_INFO_BACKREFMAX, unsafe.Pointer(&backrefMax))
+	fullinfo(C.PCRE_INFO_FIRSTCHARACTER, unsafe.Pointer(&first))
+	fullinfo(C.PCRE_INFO_FIRSTCHARACTERFLAGS, unsafe.Pointer(&firstFlags))
+	fullinfo(C.PCRE_INFO_REQUIREDCHAR, unsafe.Pointer(&required))
+	fullinfo(C.PCRE_INFO_REQUIREDCHARFLAGS, unsafe.Pointer(&requiredFlags))
+	fullinfo(C.PCRE_INFO_MINLENGTH, unsafe.Pointer(&minLength))
+	fullinfo(C.PCRE_INFO_MAXLOOKBEHIND, unsafe.Pointer(&maxLookbehind))
+	fullinfo(C.PCRE_INFO_JIT, unsafe.Pointer(&jit))
+	fullinfo(C.PCRE_INFO_JITSIZE, unsafe.Pointer(&jitSize))
+	fullinfo(C.PCRE_INFO_STUDYSIZE, unsafe.Pointer(&studySize))
+	fullinfo(C.PCRE_INFO_HASCRORLF, unsafe.Pointer(&hasCRorLF))
+	fullinfo(C.PCRE_INFO_JCHANGED, unsafe.Pointer(&jChanged))
+	fullinfo(C.PCRE_INFO_MATCH_EMPTY, unsafe.Pointer(&empty))
+	if !fullinfo(C.PCRE_INFO_MATCHLIMIT, unsafe.Pointer(&matchLimit)) {
+		matchLimit = 0
+	}
+	if !fullinfo(C.PCRE_INFO_RECURSIONLIMIT, unsafe.Pointer(&recursionLimit)) {
+		recursionLimit = 0
+	}
+	return Info{
+		Options:                int(options),
+		Size:                   int(size),
+		Groups:                 c.groups,
+		BackrefMax:             int(backrefMax),
+		FirstCharacter:         rune(first),
+		FirstCharacterFlags:    int(firstFlags),
+		RequiredCharacter:      rune(required),
+		RequiredCharacterFlags: int(requiredFlags),
+		MinLength:              int(minLength),
+		MaxLookbehind:          int(maxLookbehind),
+		JIT:                    jit != 0,
+		JITSize:                int(jitSize),
+		StudySize:              int(studySize),
+		HasCRorLF:              hasCRorLF != 0,
+		JChanged:               jChanged != 0,
+		MatchEmpty:             empty != 0,
+		MatchLimit:             int(matchLimit),
+		RecursionLimit:         int(recursionLimit),
+	}, nil
+}
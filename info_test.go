@@ -0,0 +1,59 @@
+package pcre
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestInfo(t *testing.T) {
+	re := MustCompile(`(*LIMIT_MATCH=500)(?J)(?<=x)(a)b+\1c\n`, CASELESS)
+	defer re.FreeRegexp()
+	info, err := re.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Options&CASELESS == 0 || info.Groups != 1 || info.BackrefMax != 1 {
+		t.Error("Options, Groups, BackrefMax", info)
+	}
+	if info.FirstCharacterFlags != 1 || info.FirstCharacter != 'a' ||
+		info.RequiredCharacterFlags != 1 || info.RequiredCharacter != '\n' {
+		t.Error("first and required characters", info)
+	}
+	if info.MaxLookbehind != 1 || !info.HasCRorLF || !info.JChanged ||
+		info.MatchEmpty || info.MatchLimit != 500 || info.RecursionLimit != 0 {
+		t.Error("flags and limits", info)
+	}
+	if info.Size <= 0 || info.MinLength != -1 || info.JIT || info.StudySize != 0 {
+		t.Error("unstudied pattern", info)
+	}
+
+	if err := re.Study(STUDY_JIT_COMPILE); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ = re.Info(); info.MinLength != 5 || !info.JIT ||
+		info.JITSize <= 0 || info.StudySize <= 0 {
+		t.Error("studied pattern", info)
+	}
+
+	re = MustCompile(`^a?`, 0)
+	if info, _ = re.Info(); !info.MatchEmpty ||
+		info.FirstCharacterFlags != 0 {
+		t.Error("empty match", info)
+	}
+	re.Close()
+	if _, err := re.Info(); !errors.Is(err, ErrFreed) {
+		t.Error("freed pattern", err)
+	}
+
+	re = MustCompile(`(?m)^xyz`, 0)
+	defer re.FreeRegexp()
+	if info, _ = re.Info(); info.FirstCharacterFlags != 2 {
+		t.Error("start of line", info)
+	}
+	re2 := MustCompile(`xyz`, 0)
+	defer re2.FreeRegexp()
+	if info, _ = re2.Info(); info.FirstCharacterFlags != 1 ||
+		info.FirstCharacter != 'x' || info.RequiredCharacter != 'z' {
+		t.Error("first character", info)
+	}
+}
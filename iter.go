@@ -0,0 +1,188 @@
+package pcre
+
+// #include "./pcre.h"
+import "C"
+
+import (
+	"unsafe"
+)
+
+// newlineBits masks the newline convention in compile and match flags.
+const newlineBits = NEWLINE_CR | NEWLINE_LF | NEWLINE_ANY
+
+// stepper advances global matching past a position at which no
+// non-empty match starts.  As in pcretest, it moves by a whole
+// character in UTF-8 mode, and over both characters of a CRLF pair
+// when CRLF is a newline.
+type stepper struct {
+	utf  bool // pattern is in UTF-8 mode
+	crlf bool // CRLF is a newline
+}
+
+// defaultCRLF is set if CRLF is a newline in the default newline
+// convention that PCRE was built with.
+var defaultCRLF = func() bool {
+	var config C.int
+	C.pcre_config(C.PCRE_CONFIG_NEWLINE, unsafe.Pointer(&config))
+	// CRLF is reported as 0x0d0a, ANY as -1 and ANYCRLF as -2.
+	return config == '\r'<<8|'\n' || config == -1 || config == -2
+}()
+
+// stepper returns the stepper for matches of re with the match flags.
+// A newline convention in flags overrides that of the pattern.
+func (re Regexp) stepper(flags int) stepper {
+	newline := flags & newlineBits
+	if newline == 0 {
+		newline = re.c.options & newlineBits
+	}
+	crlf := defaultCRLF
+	switch newline {
+	case NEWLINE_CRLF, NEWLINE_ANY, NEWLINE_ANYCRLF:
+		crlf = true
+	case NEWLINE_CR, NEWLINE_LF:
+		crlf = false
+	}
+	return stepper{utf: re.c.options&UTF8 != 0, crlf: crlf}
+}
+
+// step returns the position of the character after the one at pos in
+// b, or in s if b is nil.
+func (st stepper) step(b []byte, s string, pos int) int {
+	at, length := func(i int) byte { return s[i] }, len(s)
+	if b != nil {
+		at, length = func(i int) byte { return b[i] }, len(b)
+	}
+	if st.crlf && pos+1 < length && at(pos) == '\r' && at(pos+1) == '\n' {
+		return pos + 2
+	}
+	pos++
+	if st.utf {
+		for pos < length && at(pos)&0xc0 == 0x80 {
+			pos++
+		}
+	}
+	return pos
+}
+
+// noCheck returns NO_UTF8_CHECK for a search at pos of a subject that
+// an earlier search has found to be valid UTF-8, so that the searches
+// of a global match do not each check the whole subject again, as in
+// pcretest.  A position inside a character, at the end of a match of
+// \C, is checked, and fails.
+func (st stepper) noCheck(b []byte, s string, pos int) int {
+	var c byte
+	switch {
+	case b != nil && pos < len(b):
+		c = b[pos]
+	case b == nil && pos < len(s):
+		c = s[pos]
+	}
+	if c&0xc0 == 0x80 {
+		return 0
+	}
+	return NO_UTF8_CHECK
+}
+
+// Iter iterates over the successive matches of a pattern in a subject,
+// finding them one at a time.  It follows Perl and pcretest's /g:
+// after an empty match, it looks for a non-empty match at the same
+// position, with NOTEMPTY_ATSTART and ANCHORED, before moving on by one
+// character.  Unlike FindAll, it reports an empty match right after a
+// previous match.
+//
+//	it := re.IterString(subject, 0)
+//	for it.Next() {
+//		m := it.Matcher()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type Iter struct {
+	m        *Matcher
+	subjectb []byte // one of these holds the subject
+	subjects string
+	length   int
+	flags    int
+	st       stepper
+	pos      int  // offset of the next search
+	retry    int  // extra flags of the next search
+	checked  bool // a search has found the subject valid
+	done     bool // no more matches
+}
+
+// Iter returns an iterator over the matches of re in subject.
+func (re Regexp) Iter(subject []byte, flags int) *Iter {
+	return &Iter{
+		m:        re.NewMatcher(),
+		subjectb: subject,
+		length:   len(subject),
+		flags:    flags,
+		st:       re.stepper(flags),
+	}
+}
+
+// IterString is like Iter, with a string subject.
+func (re Regexp) IterString(subject string, flags int) *Iter {
+	return &Iter{
+		m:        re.NewMatcher(),
+		subjects: subject,
+		length:   len(subject),
+		flags:    flags,
+		st:       re.stepper(flags),
+	}
+}
+
+// Next advances to the next match, which is then available from
+// Matcher.  It returns false when there are no more matches or an
+// error occurs.  A partial match ends the iteration.
+func (it *Iter) Next() bool {
+	for !it.done && it.pos <= it.length {
+		flags := it.flags | it.retry
+		if it.checked {
+			flags |= it.st.noCheck(it.subjectb, it.subjects, it.pos)
+		}
+		var ok bool
+		if it.subjectb != nil {
+			ok = it.m.MatchFrom(it.subjectb, it.pos, flags)
+		} else {
+			ok = it.m.MatchStringFrom(it.subjects, it.pos, flags)
+		}
+		if ok {
+			it.checked = true
+			start, end := int(it.m.ovector[0]), int(it.m.ovector[1])
+			it.retry = 0
+			if start == end {
+				it.retry = NOTEMPTY_ATSTART | ANCHORED
+			}
+			it.pos = end
+			it.done = it.m.Partial()
+			return true
+		}
+		if it.m.Err() != nil || it.retry == 0 {
+			break
+		}
+		// No non-empty match where the empty one was.
+		it.pos = it.st.step(it.subjectb, it.subjects, it.pos)
+		it.retry = 0
+	}
+	it.done = true
+	return false
+}
+
+// Matcher returns the Matcher holding the current match, from which
+// its capture groups can be read.  It must not be used to start
+// other matches.
+func (it *Iter) Matcher() *Matcher {
+	return it.m
+}
+
+// Index returns the start and end of the current match.
+func (it *Iter) Index() []int {
+	return it.m.Index()
+}
+
+// Err returns the error that ended the iteration, if any.
+func (it *Iter) Err() error {
+	return it.m.Err()
+}
@@ -0,0 +1,96 @@
+package pcre
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func iterIndices(it *Iter) [][]int {
+	var all [][]int
+	for it.Next() {
+		all = append(all, it.Index())
+	}
+	return all
+}
+
+func TestIter(t *testing.T) {
+	tests := []struct {
+		pattern string
+		flags   int
+		subject string
+		want    [][]int
+	}{
+		{`\d+`, 0, "a1b22", [][]int{{1, 2}, {3, 5}}},
+		{`\d+`, 0, "none", nil},
+		// Empty matches are reported as Perl does.
+		{`a*`, 0, "baaab", [][]int{{0, 0}, {1, 4}, {4, 4}, {5, 5}}},
+		{`x*`, 0, "", [][]int{{0, 0}}},
+		// A non-empty match at the position of an empty one.
+		{`(?=a)|a`, 0, "aa", [][]int{{0, 0}, {0, 1}, {1, 1}, {1, 2}}},
+		// UTF-8 mode advances by whole characters.
+		{`x?`, UTF8, "é€", [][]int{{0, 0}, {2, 2}, {5, 5}}},
+		{`(*UTF8)x?`, 0, "éa", [][]int{{0, 0}, {2, 2}, {3, 3}}},
+		// CRLF is stepped over as one newline.
+		{`x?`, NEWLINE_CRLF, "\r\n", [][]int{{0, 0}, {2, 2}}},
+		{`(*ANYCRLF)x?`, 0, "a\r\n", [][]int{{0, 0}, {1, 1}, {3, 3}}},
+		{`x?`, 0, "\r\n", [][]int{{0, 0}, {1, 1}, {2, 2}}},
+	}
+	for _, test := range tests {
+		re := MustCompile(test.pattern, test.flags)
+		if got := iterIndices(re.IterString(test.subject, 0)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("IterString(%q, %q) = %v, want %v",
+				test.pattern, test.subject, got, test.want)
+		}
+		if got := iterIndices(re.Iter([]byte(test.subject), 0)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Iter(%q, %q) = %v, want %v",
+				test.pattern, test.subject, got, test.want)
+		}
+		re.FreeRegexp()
+	}
+}
+
+func TestIterMatchFlags(t *testing.T) {
+	re := MustCompile(`x?`, 0)
+	defer re.FreeRegexp()
+	got := iterIndices(re.IterString("\r\n", NEWLINE_CRLF))
+	if want := [][]int{{0, 0}, {2, 2}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestIterGroups(t *testing.T) {
+	re := MustCompile(`(?<k>\w+)=(\w*)`, 0)
+	defer re.FreeRegexp()
+	it := re.IterString("a=1 b= c=3", 0)
+	var got []string
+	for it.Next() {
+		m := it.Matcher()
+		k, _ := m.NamedString("k")
+		got = append(got, k+":"+m.GroupString(2))
+	}
+	if want := []string{"a:1", "b:", "c:3"}; !reflect.DeepEqual(got, want) || it.Err() != nil {
+		t.Errorf("got %q, want %q (%v)", got, want, it.Err())
+	}
+}
+
+func TestIterErrors(t *testing.T) {
+	re := MustCompile(`.`, UTF8)
+	defer re.FreeRegexp()
+	it := re.IterString("a\xff", 0)
+	if it.Next() || !errors.Is(it.Err(), ErrBadUTF8) {
+		t.Error("Err", it.Err())
+	}
+	if it.Next() {
+		t.Error("Next after error")
+	}
+
+	re2 := MustCompile(`abc`, 0)
+	defer re2.FreeRegexp()
+	it = re2.IterString("abc ab", PARTIAL_HARD)
+	got := iterIndices(it)
+	if want := [][]int{{0, 3}, {4, 6}}; !reflect.DeepEqual(got, want) ||
+		!it.Matcher().Partial() {
+		t.Errorf("partial: got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,134 @@
+package pcre
+
+// #include "./pcre.h"
+import "C"
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+)
+
+// JITStack is a machine stack for matching JIT-compiled patterns.
+// Without one, JIT matches run on a 32K stack and deep patterns fail
+// with ERROR_JIT_STACKLIMIT.  A JITStack may only be used by one match
+// at a time: assign it with Matcher.SetJITStack to give a goroutine its
+// own stack, or share a JITStackPool between goroutines.
+type JITStack struct {
+	ptr *C.pcre_jit_stack
+}
+
+// NewJITStack allocates a JIT stack of start bytes, which grows as
+// needed up to max bytes.
+func NewJITStack(start, max int) (*JITStack, error) {
+	if start < 1 || max < start {
+		return nil, errors.New("pcre: invalid JIT stack size")
+	}
+	ptr := C.pcre_jit_stack_alloc(C.int(start), C.int(max))
+	if ptr == nil {
+		return nil, errors.New("pcre: cannot allocate JIT stack")
+	}
+	s := &JITStack{ptr: ptr}
+	runtime.SetFinalizer(s, (*JITStack).Free)
+	return s, nil
+}
+
+// Free frees the C memory of the stack.  It must not be called while
+// a match is using the stack.  If Free is not called, the memory is
+// freed once the stack is no longer reachable.
+func (s *JITStack) Free() {
+	if s.ptr != nil {
+		C.pcre_jit_stack_free(s.ptr)
+		s.ptr = nil
+	}
+	runtime.SetFinalizer(s, nil)
+}
+
+// cptr returns the C stack, or nil for the default stack.
+func (s *JITStack) cptr() *C.pcre_jit_stack {
+	if s == nil {
+		return nil
+	}
+	return s.ptr
+}
+
+// JITStackPool hands out JIT stacks of the same size to concurrent
+// matches.  Stacks are allocated on demand and freed when the pool
+// drops them.
+type JITStackPool struct {
+	start, max int
+	pool       sync.Pool
+}
+
+// NewJITStackPool creates a pool of JIT stacks allocated with
+// NewJITStack(start, max).
+func NewJITStackPool(start, max int) *JITStackPool {
+	return &JITStackPool{start: start, max: max}
+}
+
+// Get takes a stack from the pool, allocating one if the pool is empty.
+func (p *JITStackPool) Get() (*JITStack, error) {
+	if s, ok := p.pool.Get().(*JITStack); ok && s.ptr != nil {
+		return s, nil
+	}
+	return NewJITStack(p.start, p.max)
+}
+
+// Put returns a stack obtained with Get to the pool.
+func (p *JITStackPool) Put(s *JITStack) {
+	p.pool.Put(s)
+}
+
+// SetJITStack sets the stack used by JIT-compiled matches with this
+// pattern.  As a stack serves one match at a time, use it only when
+// the pattern is not matched concurrently; otherwise use
+// SetJITStackPool or Matcher.SetJITStack.  Passing nil restores the
+// default stack.  SetJITStack must not be called while the pattern is
+// in use by other goroutines.
+func (re *Regexp) SetJITStack(s *JITStack) {
+	if re.c == nil {
+		panic("Regexp.SetJITStack: uninitialized")
+	}
+	re.c.jitStack = s
+}
+
+// SetJITStackPool makes JIT-compiled matches with this pattern take
+// their stack from p, so that concurrent matches use separate stacks.
+// A stack set with SetJITStack takes precedence.  Passing nil removes
+// the pool.  SetJITStackPool must not be called while the pattern is
+// in use by other goroutines.
+func (re *Regexp) SetJITStackPool(p *JITStackPool) {
+	if re.c == nil {
+		panic("Regexp.SetJITStackPool: uninitialized")
+	}
+	re.c.jitPool = p
+}
+
+// SetJITStack sets the stack used by JIT-compiled matches performed by
+// this Matcher, overriding the stack or pool of the Regexp.  Passing
+// nil falls back to those of the Regexp.
+func (m *Matcher) SetJITStack(s *JITStack) {
+	m.jitStack = s
+}
+
+// stack returns the JIT stack for the next match, or nil for the
+// default stack.  If the stack was taken from a pool, that pool is
+// returned too, and the stack must be put back after the match.
+func (m *Matcher) stack() (*JITStack, *JITStackPool, error) {
+	c := m.re.c
+	switch {
+	case !c.jit:
+		return nil, nil, nil
+	case m.jitStack != nil:
+		return m.jitStack, nil, nil
+	case c.jitStack != nil:
+		return c.jitStack, nil, nil
+	case c.jitPool != nil:
+		s, err := c.jitPool.Get()
+		if err != nil {
+			return nil, nil, err
+		}
+		return s, c.jitPool, nil
+	}
+	return nil, nil, nil
+}
@@ -0,0 +1,66 @@
+package pcre
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// deepJIT returns a JIT-compiled pattern and a subject whose match
+// overflows the default JIT stack.
+func deepJIT() (Regexp, string) {
+	re := MustCompileJIT(`(?:a|b)*c`, 0, STUDY_JIT_COMPILE)
+	return re, string(bytes.Repeat([]byte("ab"), 100000)) + "c"
+}
+
+func TestJITStack(t *testing.T) {
+	re, subject := deepJIT()
+	defer re.FreeRegexp()
+	m := re.NewMatcher()
+	if m.MatchString(subject, 0) || !errors.Is(m.Err(), ErrJITStackLimit) {
+		t.Fatal("default stack", m.Err())
+	}
+
+	s, err := NewJITStack(32*1024, 16*1024*1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Free()
+	re.SetJITStack(s)
+	if m = re.MatcherString(subject, 0); !m.Matches() {
+		t.Error("Regexp stack", m.Err())
+	}
+	re.SetJITStack(nil)
+
+	m = re.NewMatcher()
+	m.SetJITStack(s)
+	if !m.MatchString(subject, 0) {
+		t.Error("Matcher stack", m.Err())
+	}
+
+	if _, err := NewJITStack(0, 1024); err == nil {
+		t.Error("invalid size accepted")
+	}
+}
+
+func TestJITStackPool(t *testing.T) {
+	re, subject := deepJIT()
+	defer re.FreeRegexp()
+	re.SetJITStackPool(NewJITStackPool(32*1024, 16*1024*1024))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m := re.NewMatcher()
+			for j := 0; j < 10; j++ {
+				if !m.MatchString(subject, 0) {
+					t.Error("pooled stack", m.Err())
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
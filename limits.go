@@ -0,0 +1,103 @@
+package pcre
+
+// #include <stdint.h>
+// #include "./pcre.h"
+import "C"
+
+import (
+	"unsafe"
+)
+
+// defaultMatchLimit is the match limit that PCRE was built with.
+var defaultMatchLimit = func() int {
+	var limit C.ulong
+	C.pcre_config(C.PCRE_CONFIG_MATCH_LIMIT, unsafe.Pointer(&limit))
+	return int(limit)
+}()
+
+// watchedMatchLimit is the match limit of the first run of a watched
+// match of a pattern without callouts.  See Matcher.exec.
+const watchedMatchLimit = 1 << 16
+
+// SetMatchLimit limits the number of times the internal match function
+// of pcre_exec may be called during a match with this pattern, which
+// bounds the time spent on pathological patterns and subjects.  Matches
+// that exceed the limit fail with ErrMatchLimit.  A limit of 0 restores
+// the compile-time default.  A (*LIMIT_MATCH=n) setting at the start of
+// the pattern can only lower the limit.
+// SetMatchLimit must not be called while the pattern is in use by other
+// goroutines; use Matcher.SetMatchLimit for per-match limits.
+func (re *Regexp) SetMatchLimit(limit int) {
+	if re.c == nil {
+		panic("Regexp.SetMatchLimit: uninitialized")
+	}
+	re.c.matchLimit = limit
+}
+
+// SetRecursionLimit limits the depth of recursion of the internal match
+// function of pcre_exec, which bounds the stack used by a match.
+// Matches that exceed the limit fail with ErrRecursionLimit.  A limit of
+// 0 restores the compile-time default.  The limit is ignored by
+// JIT-compiled patterns, whose stack is bounded by the JIT stack.
+// SetRecursionLimit must not be called while the pattern is in use by
+// other goroutines; use Matcher.SetRecursionLimit for per-match limits.
+func (re *Regexp) SetRecursionLimit(limit int) {
+	if re.c == nil {
+		panic("Regexp.SetRecursionLimit: uninitialized")
+	}
+	re.c.recursionLimit = limit
+}
+
+// PatternMatchLimit returns the match limit set by a (*LIMIT_MATCH=n)
+// item at the start of the pattern.  ok is false if there is none.
+func (re Regexp) PatternMatchLimit() (limit int, ok bool) {
+	return re.infoLimit(C.PCRE_INFO_MATCHLIMIT)
+}
+
+// PatternRecursionLimit returns the recursion limit set by a
+// (*LIMIT_RECURSION=n) item at the start of the pattern.  ok is false
+// if there is none.
+func (re Regexp) PatternRecursionLimit() (limit int, ok bool) {
+	return re.infoLimit(C.PCRE_INFO_RECURSIONLIMIT)
+}
+
+func (re Regexp) infoLimit(what C.int) (int, bool) {
+	if re.c == nil {
+		panic("Regexp: uninitialized")
+	}
+	if !re.c.acquire() {
+		return 0, false
+	}
+	defer re.c.release()
+	var limit C.uint32_t
+	if C.pcre_fullinfo(re.c.ptr, nil, what, unsafe.Pointer(&limit)) != 0 {
+		return 0, false
+	}
+	return int(limit), true
+}
+
+// SetMatchLimit sets the match limit for matches performed by this
+// Matcher, overriding the limit of the Regexp.  A limit of 0 falls back
+// to the limit of the Regexp.  See Regexp.SetMatchLimit.
+func (m *Matcher) SetMatchLimit(limit int) {
+	m.matchLimit = limit
+}
+
+// SetRecursionLimit sets the recursion limit for matches performed by
+// this Matcher, overriding the limit of the Regexp.  A limit of 0 falls
+// back to the limit of the Regexp.  See Regexp.SetRecursionLimit.
+func (m *Matcher) SetRecursionLimit(limit int) {
+	m.recursionLimit = limit
+}
+
+// limits returns the limits in effect for the next match, 0 if unset.
+func (m *Matcher) limits() (matchLimit, recursionLimit int) {
+	matchLimit, recursionLimit = m.matchLimit, m.recursionLimit
+	if matchLimit == 0 {
+		matchLimit = m.re.c.matchLimit
+	}
+	if recursionLimit == 0 {
+		recursionLimit = m.re.c.recursionLimit
+	}
+	return
+}
@@ -0,0 +1,60 @@
+package pcre
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestMatchLimit(t *testing.T) {
+	re := MustCompile(`(a|aa)+$`, 0)
+	defer re.FreeRegexp()
+	subject := string(bytes.Repeat([]byte("a"), 40)) + "b"
+
+	m := re.NewMatcher()
+	m.SetMatchLimit(1000)
+	if m.MatchString(subject, 0) {
+		t.Error("unexpected match")
+	}
+	if !errors.Is(m.Err(), ErrMatchLimit) {
+		t.Error("Matcher limit", m.Err())
+	}
+
+	re.SetMatchLimit(1000)
+	if _, err := re.FindAll(subject, 0); !errors.Is(err, ErrMatchLimit) {
+		t.Error("Regexp limit", err)
+	}
+	// A Matcher limit overrides the limit of the Regexp.
+	re.SetMatchLimit(10)
+	m = re.NewMatcher()
+	m.SetMatchLimit(1000000)
+	if m.MatchString("aaaaab", 0) || m.Err() != nil {
+		t.Error("Matcher override", m.Err())
+	}
+}
+
+func TestRecursionLimit(t *testing.T) {
+	re := MustCompile(`(a|b)*c`, 0)
+	defer re.FreeRegexp()
+	re.SetRecursionLimit(10)
+	m := re.MatcherString(string(bytes.Repeat([]byte("ab"), 50))+"c", 0)
+	if m.Matches() || !errors.Is(m.Err(), ErrRecursionLimit) {
+		t.Error("recursion limit", m.Err())
+	}
+}
+
+func TestPatternLimits(t *testing.T) {
+	re := MustCompile(`(*LIMIT_MATCH=500)(*LIMIT_RECURSION=20)abc`, 0)
+	defer re.FreeRegexp()
+	if limit, ok := re.PatternMatchLimit(); !ok || limit != 500 {
+		t.Error("PatternMatchLimit", limit, ok)
+	}
+	if limit, ok := re.PatternRecursionLimit(); !ok || limit != 20 {
+		t.Error("PatternRecursionLimit", limit, ok)
+	}
+	re2 := MustCompile(`abc`, 0)
+	defer re2.FreeRegexp()
+	if _, ok := re2.PatternMatchLimit(); ok {
+		t.Error("PatternMatchLimit without limit")
+	}
+}
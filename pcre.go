@@ -1,17 +1,94 @@
 package pcre
 
 // #cgo CFLAGS: -DPCRE_STATIC
+// #include <stdint.h>
 // #include <string.h>
 // #include "./pcre.h"
 // static inline void pcre_free_stub(void *re) {
 //     pcre_free(re);
 // }
+// #define GO_PCRE_ERROR_CANCELED (-1000)
+// // go_pcre_callout_data is passed as callout data by matches
+// // that have a Go callout or a context.
+// typedef struct {
+//     uintptr_t handle;        // callout registry handle, or 0
+//     volatile int32_t cancel; // nonzero to abandon the match
+// } go_pcre_callout_data;
+// extern int goCallout(pcre_callout_block *, uintptr_t);
+// static int go_pcre_callout(pcre_callout_block *block) {
+//     go_pcre_callout_data *data = block->callout_data;
+//     // Matches started without callout data simply continue.
+//     if (data == NULL)
+//         return 0;
+//     if (data->cancel)
+//         return GO_PCRE_ERROR_CANCELED;
+//     if (data->handle == 0)
+//         return 0;
+//     return goCallout(block, data->handle);
+// }
+// static void go_pcre_init(void) {
+//     pcre_callout = go_pcre_callout;
+// }
+// // go_pcre_jit_stack is the JIT stack of the match running on this
+// // thread.  Studied patterns get go_pcre_jit_callback as their JIT
+// // stack callback, so that each match can bring its own stack while
+// // the pattern is shared.  NULL selects PCRE's default 32K stack.
+// static __thread pcre_jit_stack *go_pcre_jit_stack;
+// static pcre_jit_stack *go_pcre_jit_callback(void *unused) {
+//     return go_pcre_jit_stack;
+// }
+// static void go_pcre_assign_jit_stack(pcre_extra *extra) {
+//     pcre_assign_jit_stack(extra, go_pcre_jit_callback, NULL);
+// }
+// // go_pcre_exec calls pcre_exec with a private copy of extra,
+// // so that per-match settings do not affect other users of the
+// // compiled pattern.  *mark receives the name of the last mark.
+// static int go_pcre_exec(const pcre *code, const pcre_extra *extra,
+//         const char *subject, int length, int start, int options,
+//         int *ovector, int ovecsize, go_pcre_callout_data *callout,
+//         unsigned long match_limit, unsigned long recursion_limit,
+//         pcre_jit_stack *jit_stack, unsigned char **mark) {
+//     pcre_extra e;
+//     pcre_jit_stack *saved;
+//     int rc;
+//     if (extra != NULL)
+//         e = *extra;
+//     else
+//         memset(&e, 0, sizeof(e));
+//     if (match_limit != 0) {
+//         e.flags |= PCRE_EXTRA_MATCH_LIMIT;
+//         e.match_limit = match_limit;
+//     }
+//     if (recursion_limit != 0) {
+//         e.flags |= PCRE_EXTRA_MATCH_LIMIT_RECURSION;
+//         e.match_limit_recursion = recursion_limit;
+//     }
+//     e.flags |= PCRE_EXTRA_MARK;
+//     e.mark = mark;
+//     *mark = NULL;
+//     if (callout != NULL) {
+//         if (callout->cancel)
+//             return GO_PCRE_ERROR_CANCELED;
+//         e.flags |= PCRE_EXTRA_CALLOUT_DATA;
+//         e.callout_data = callout;
+//     }
+//     // A callout may run a nested match on this thread.
+//     saved = go_pcre_jit_stack;
+//     go_pcre_jit_stack = jit_stack;
+//     rc = pcre_exec(code, &e, subject, length, start, options,
+//         ovector, ovecsize);
+//     go_pcre_jit_stack = saved;
+//     return rc;
+// }
 import "C"
 
 import (
 	"errors"
 	"fmt"
+	"runtime"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"unsafe"
 )
 
@@ -45,6 +122,7 @@ const (
 	UNGREEDY          = C.PCRE_UNGREEDY
 	UTF8              = C.PCRE_UTF8
 	UCP               = C.PCRE_UCP
+	AUTO_CALLOUT      = C.PCRE_AUTO_CALLOUT
 )
 
 // Flags for Match functions
@@ -62,6 +140,7 @@ const (
 	STUDY_JIT_COMPILE              = C.PCRE_STUDY_JIT_COMPILE
 	STUDY_JIT_PARTIAL_SOFT_COMPILE = C.PCRE_STUDY_JIT_PARTIAL_SOFT_COMPILE
 	STUDY_JIT_PARTIAL_HARD_COMPILE = C.PCRE_STUDY_JIT_PARTIAL_HARD_COMPILE
+	STUDY_EXTRA_NEEDED             = C.PCRE_STUDY_EXTRA_NEEDED
 )
 
 // Exec-time and get/set-time error codes
@@ -84,14 +163,127 @@ const (
 	ERROR_INTERNAL       = C.PCRE_ERROR_INTERNAL
 	ERROR_BADCOUNT       = C.PCRE_ERROR_BADCOUNT
 	ERROR_JIT_STACKLIMIT = C.PCRE_ERROR_JIT_STACKLIMIT
+	ERROR_DFA_UITEM      = C.PCRE_ERROR_DFA_UITEM
+	ERROR_DFA_UCOND      = C.PCRE_ERROR_DFA_UCOND
+	ERROR_DFA_UMLIMIT    = C.PCRE_ERROR_DFA_UMLIMIT
+	ERROR_DFA_WSSIZE     = C.PCRE_ERROR_DFA_WSSIZE
+	ERROR_DFA_RECURSE    = C.PCRE_ERROR_DFA_RECURSE
+	ERROR_BADNEWLINE     = C.PCRE_ERROR_BADNEWLINE
+	ERROR_BADOFFSET      = C.PCRE_ERROR_BADOFFSET
+	ERROR_SHORTUTF8      = C.PCRE_ERROR_SHORTUTF8
+	ERROR_RECURSELOOP    = C.PCRE_ERROR_RECURSELOOP
+	ERROR_BADMODE        = C.PCRE_ERROR_BADMODE
+	ERROR_BADENDIANNESS  = C.PCRE_ERROR_BADENDIANNESS
+	ERROR_DFA_BADRESTART = C.PCRE_ERROR_DFA_BADRESTART
+	ERROR_JIT_BADOPTION  = C.PCRE_ERROR_JIT_BADOPTION
+	ERROR_BADLENGTH      = C.PCRE_ERROR_BADLENGTH
+	ERROR_UNSET          = C.PCRE_ERROR_UNSET
 )
 
 // Regexp holds a reference to a compiled regular expression.
 // Use Compile or MustCompile to create such objects.
-// Use FreeRegexp to free memory when done with the struct.
+// Copies of a Regexp, and the Matchers created from it, share the
+// compiled pattern and may be used from several goroutines at once.
+// The methods of Regexp are safe for concurrent use, except for Study
+// and the setters, which must be called before the pattern is shared.
+// A Matcher holds the state of a match and must be used by only one
+// goroutine at a time; the matching methods of Regexp take Matchers
+// from a pool of the pattern, so that they allocate little.
+// Use Close or FreeRegexp to free memory when done with the pattern;
+// if neither is called, the memory is freed once the pattern is no
+// longer reachable.
 type Regexp struct {
-	ptr   *C.pcre
-	extra *C.pcre_extra
+	c *code
+}
+
+// code owns the C memory of a compiled pattern.
+type code struct {
+	ptr    *C.pcre
+	extra  *C.pcre_extra
+	groups int
+	// options holds the compile options reported by
+	// PCRE_INFO_OPTIONS, including those set by the pattern.
+	options int
+	// width is 16 or 32 for patterns of Compile16 and Compile32,
+	// whose ptr and extra belong to the pcre16 or pcre32 library.
+	width int
+	// Limits set with SetMatchLimit and SetRecursionLimit, 0 if unset.
+	matchLimit     int
+	recursionLimit int
+	// jit is set if Study JIT-compiled the pattern.  Its matches use
+	// the stack set with SetJITStack, or one from the pool set with
+	// SetJITStackPool.
+	jit      bool
+	jitStack *JITStack
+	jitPool  *JITStackPool
+	// empty is set if the pattern was compiled from the empty
+	// string, which Split treats specially.  It is not saved by
+	// MarshalBinary.
+	empty bool
+	// studyFlags holds the flags passed to pcre_study, so that
+	// patterns loaded by UnmarshalBinary can be studied again.
+	studyFlags int
+	// matchers pools the Matchers of the methods of Regexp.  Pooled
+	// Matchers do not refer to the pattern, so that the pool does not
+	// keep it alive.
+	matchers sync.Pool
+	// refs counts the owning reference, dropped by Close, plus one
+	// reference per call into PCRE in progress.  The C memory is
+	// freed when refs drops to zero.
+	refs   int32
+	closed int32
+}
+
+// ErrFreed is returned when a Regexp is used after Close or FreeRegexp.
+var ErrFreed = errors.New("pcre: use of freed Regexp")
+
+func newCode(ptr *C.pcre) *code {
+	c := &code{ptr: ptr, groups: int(pcreGroups(ptr)),
+		options: int(pcreOptions(ptr)), refs: 1}
+	runtime.SetFinalizer(c, (*code).free)
+	return c
+}
+
+// acquire takes a reference for a call into PCRE.  It returns false if
+// the pattern has already been freed.
+func (c *code) acquire() bool {
+	for {
+		refs := atomic.LoadInt32(&c.refs)
+		if refs == 0 {
+			return false
+		}
+		if atomic.CompareAndSwapInt32(&c.refs, refs, refs+1) {
+			return true
+		}
+	}
+}
+
+// release drops a reference taken by acquire, freeing the C memory
+// after the last one.
+func (c *code) release() {
+	if atomic.AddInt32(&c.refs, -1) == 0 {
+		c.free()
+	}
+}
+
+func (c *code) free() {
+	if c.width != 0 {
+		c.freeWide()
+	}
+	// pcre_free is a function pointer, call a stub that calls it.
+	if c.ptr != nil {
+		C.pcre_free_stub(unsafe.Pointer(c.ptr))
+		c.ptr = nil
+	}
+	if c.extra != nil {
+		C.pcre_free_study(c.extra)
+		c.extra = nil
+	}
+	runtime.SetFinalizer(c, nil)
+}
+
+func init() {
+	C.go_pcre_init()
 }
 
 // Number of bytes in the compiled pattern
@@ -100,6 +292,12 @@ func pcreSize(ptr *C.pcre) (size C.size_t) {
 	return
 }
 
+// Compile options
+func pcreOptions(ptr *C.pcre) (options C.ulong) {
+	C.pcre_fullinfo(ptr, nil, C.PCRE_INFO_OPTIONS, unsafe.Pointer(&options))
+	return
+}
+
 // Number of capture groups
 func pcreGroups(ptr *C.pcre) (count C.int) {
 	C.pcre_fullinfo(ptr, nil,
@@ -108,22 +306,29 @@ func pcreGroups(ptr *C.pcre) (count C.int) {
 }
 
 // Free c allocated memory related to regexp.
+// It is equivalent to Close, ignoring the error.
 func (re *Regexp) FreeRegexp() {
-	// pcre_free is a function pointer, call a stub that calls it.
-	if re.ptr != nil {
-		C.pcre_free_stub(unsafe.Pointer(re.ptr))
-		re.ptr = nil
+	re.Close()
+}
+
+// Close frees the C memory of the compiled pattern.  Matches already in
+// progress in other goroutines complete first; any later use of the
+// pattern, through any copy of re or any Matcher, fails with ErrFreed.
+// Closing a pattern more than once returns ErrFreed.
+func (re *Regexp) Close() error {
+	if re.c == nil {
+		return nil
 	}
-	if re.extra != nil {
-		C.pcre_free_study(re.extra)
-		re.extra = nil
+	if !atomic.CompareAndSwapInt32(&re.c.closed, 0, 1) {
+		return ErrFreed
 	}
+	re.c.release()
+	return nil
 }
 
 // Compile the pattern and return a compiled regexp.
 // If compilation fails, the second return value holds a *CompileError.
 func Compile(pattern string, flags int) (Regexp, error) {
-	re := Regexp{}
 	pattern1 := C.CString(pattern)
 	defer C.free(unsafe.Pointer(pattern1))
 	if clen := int(C.strlen(pattern1)); clen != len(pattern) {
@@ -135,15 +340,17 @@ func Compile(pattern string, flags int) (Regexp, error) {
 	}
 	var errptr *C.char
 	var erroffset C.int
-	re.ptr = C.pcre_compile(pattern1, C.int(flags), &errptr, &erroffset, nil)
-	if re.ptr == nil {
+	ptr := C.pcre_compile(pattern1, C.int(flags), &errptr, &erroffset, nil)
+	if ptr == nil {
 		return Regexp{}, &CompileError{
 			Pattern: pattern,
 			Message: C.GoString(errptr),
 			Offset:  int(erroffset),
 		}
 	}
-	return re, nil
+	c := newCode(ptr)
+	c.empty = pattern == ""
+	return Regexp{c}, nil
 }
 
 // CompileJIT is a combination of Compile and Study. It first compiles
@@ -179,8 +386,16 @@ func MustCompileJIT(pattern string, comFlags, jitFlags int) (re Regexp) {
 // Study adds Just-In-Time compilation to a Regexp. This may give a huge
 // speed boost when matching. If an error occurs, return value is non-nil.
 // Flags optionally specifies JIT compilation options for partial matches.
+// Study must not be called while the pattern is in use by other goroutines.
 func (re *Regexp) Study(flags int) error {
-	if re.extra != nil {
+	if re.c == nil {
+		panic("Regexp.Study: uninitialized")
+	}
+	if !re.c.acquire() {
+		return ErrFreed
+	}
+	defer re.c.release()
+	if re.c.extra != nil {
 		return fmt.Errorf("Study: Regexp has already been optimized")
 	}
 	if flags == 0 {
@@ -188,23 +403,95 @@ func (re *Regexp) Study(flags int) error {
 	}
 
 	var err *C.char
-	re.extra = C.pcre_study(re.ptr, C.int(flags), &err)
+	re.c.studyFlags = flags
+	re.c.extra = C.pcre_study(re.c.ptr, C.int(flags), &err)
 	if err != nil {
 		return fmt.Errorf("%s", C.GoString(err))
 	}
-	if re.extra == nil {
+	if re.c.extra == nil {
 		// Studying the pattern may not produce useful information.
 		return nil
 	}
+	var jit C.int
+	C.pcre_fullinfo(re.c.ptr, re.c.extra, C.PCRE_INFO_JIT, unsafe.Pointer(&jit))
+	if jit != 0 {
+		re.c.jit = true
+		C.go_pcre_assign_jit_stack(re.c.extra)
+	}
 	return nil
 }
 
 // Groups returns the number of capture groups in the compiled pattern.
 func (re Regexp) Groups() int {
-	if re.ptr == nil {
+	if re.c == nil {
 		panic("Regexp.Groups: uninitialized")
 	}
-	return int(pcreGroups(re.ptr))
+	return re.c.groups
+}
+
+// SubexpNames returns the names of the capture groups in the compiled
+// pattern.  names[0] stands for the whole match and is always empty;
+// names[i] is the name of group i, or empty if the group is unnamed.
+// SubexpNames returns nil if the pattern has been freed.
+func (re Regexp) SubexpNames() []string {
+	if re.c == nil {
+		panic("Regexp.SubexpNames: uninitialized")
+	}
+	if !re.c.acquire() {
+		return nil
+	}
+	defer re.c.release()
+	names := make([]string, re.c.groups+1)
+	re.c.nameTable(func(group int, name string) {
+		names[group] = name
+	})
+	return names
+}
+
+// SubexpIndices maps the names of the capture groups in the compiled
+// pattern to their group numbers, in increasing order.  Names have
+// several groups when compiled with DUPNAMES or (?J).
+// SubexpIndices returns nil if the pattern has been freed.
+func (re Regexp) SubexpIndices() map[string][]int {
+	if re.c == nil {
+		panic("Regexp.SubexpIndices: uninitialized")
+	}
+	if !re.c.acquire() {
+		return nil
+	}
+	defer re.c.release()
+	indices := make(map[string][]int)
+	re.c.nameTable(func(group int, name string) {
+		indices[name] = append(indices[name], group)
+	})
+	return indices
+}
+
+// nameTable calls f for each entry of the name table of the pattern,
+// in order of name and then group number.
+func (c *code) nameTable(f func(group int, name string)) {
+	var count, size C.int
+	var table *C.uchar
+	C.pcre_fullinfo(c.ptr, nil,
+		C.PCRE_INFO_NAMECOUNT, unsafe.Pointer(&count))
+	C.pcre_fullinfo(c.ptr, nil,
+		C.PCRE_INFO_NAMEENTRYSIZE, unsafe.Pointer(&size))
+	C.pcre_fullinfo(c.ptr, nil,
+		C.PCRE_INFO_NAMETABLE, unsafe.Pointer(&table))
+	for i := 0; i < int(count); i++ {
+		entry := unsafe.Pointer(uintptr(unsafe.Pointer(table)) +
+			uintptr(i)*uintptr(size))
+		f(entryGroup(entry), C.GoString((*C.char)(unsafe.Pointer(
+			uintptr(entry)+2))))
+	}
+}
+
+// entryGroup returns the group number of a name table entry.  Each
+// entry holds the group number as two bytes in big-endian order,
+// followed by the NUL-terminated name.
+func entryGroup(entry unsafe.Pointer) int {
+	b := C.GoBytes(entry, 2)
+	return int(b[0])<<8 | int(b[1])
 }
 
 // Matcher objects provide a place for storing match results.
@@ -219,6 +506,15 @@ type Matcher struct {
 	subjects string  // one of these fields is set to record the subject,
 	subjectb []byte  // so that Group/GroupString can return slices
 	err      error
+	callout  CalloutFunc
+	watch    *C.go_pcre_callout_data // set while a context is watched
+	// Limits set with SetMatchLimit and SetRecursionLimit,
+	// overriding those of the Regexp, 0 if unset.
+	matchLimit     int
+	recursionLimit int
+	jitStack       *JITStack // set with SetJITStack
+	markptr        *C.uchar  // receives the mark of pcre_exec
+	mark           string
 }
 
 // NewMatcher creates a new matcher object for the given Regexp.
@@ -228,6 +524,27 @@ func (re Regexp) NewMatcher() (m *Matcher) {
 	return
 }
 
+// getMatcher returns a Matcher for re from the pool of the pattern.
+// Return it with putMatcher when done.
+func (re Regexp) getMatcher() *Matcher {
+	if re.c == nil {
+		panic("Regexp: uninitialized")
+	}
+	m, _ := re.c.matchers.Get().(*Matcher)
+	if m == nil {
+		m = new(Matcher)
+	}
+	m.Init(&re)
+	return m
+}
+
+// putMatcher returns m to the pool of re.  Only its offset vector is
+// kept, so m must not be used afterwards.
+func (re Regexp) putMatcher(m *Matcher) {
+	*m = Matcher{ovector: m.ovector}
+	re.c.matchers.Put(m)
+}
+
 // Matcher creates a new matcher object, with the byte slice as subject.
 // It also starts a first match on subject. Test for success with Matches().
 func (re Regexp) Matcher(subject []byte, flags int) (m *Matcher) {
@@ -261,12 +578,12 @@ func (m *Matcher) ResetString(re Regexp, subject string, flags int) bool {
 
 // Init binds an existing Matcher object to the given Regexp.
 func (m *Matcher) Init(re *Regexp) {
-	if re.ptr == nil {
+	if re.c == nil {
 		panic("Matcher.Init: uninitialized")
 	}
 	m.matches = false
 	m.err = nil
-	if m.re.ptr != nil && m.re.ptr == re.ptr {
+	if m.re.c == re.c {
 		// Skip group count extraction if the matcher has
 		// already been initialized with the same regular
 		// expression.
@@ -291,30 +608,44 @@ var nullbyte = []byte{0}
 // Returns true if the match succeeds.
 // Match is a no-op if err is not nil.
 func (m *Matcher) Match(subject []byte, flags int) bool {
+	return m.MatchFrom(subject, 0, flags)
+}
+
+// MatchString tries to match the specified subject string to
+// the current pattern by calling ExecString and collects the result.
+// Returns true if the match succeeds.
+func (m *Matcher) MatchString(subject string, flags int) bool {
+	return m.MatchStringFrom(subject, 0, flags)
+}
+
+// MatchFrom is like Match, but the search starts at byte offset start
+// within subject.  The whole subject remains visible to the pattern, so
+// lookbehind assertions, \b, \G and ^ with MULTILINE see the text
+// before start.  Group offsets are relative to the start of subject.
+func (m *Matcher) MatchFrom(subject []byte, start, flags int) bool {
 	if m.err != nil {
 		return false
 	}
-	if m.re.ptr == nil {
-		panic("Matcher.Match: uninitialized")
+	if m.re.c == nil {
+		panic("Matcher.MatchFrom: uninitialized")
 	}
-	rc := m.Exec(subject, flags)
-	m.matches, m.err = matched(rc)
+	rc := m.ExecFrom(subject, start, flags)
+	m.matches, m.err = matched(rc, m.ovector)
 	m.partial = (rc == ERROR_PARTIAL)
 	return m.matches
 }
 
-// MatchString tries to match the specified subject string to
-// the current pattern by calling ExecString and collects the result.
-// Returns true if the match succeeds.
-func (m *Matcher) MatchString(subject string, flags int) bool {
+// MatchStringFrom is like MatchString, but the search starts at byte
+// offset start within subject.  See MatchFrom.
+func (m *Matcher) MatchStringFrom(subject string, start, flags int) bool {
 	if m.err != nil {
 		return false
 	}
-	if m.re.ptr == nil {
-		panic("Matcher.MatchString: uninitialized")
+	if m.re.c == nil {
+		panic("Matcher.MatchStringFrom: uninitialized")
 	}
-	rc := m.ExecString(subject, flags)
-	m.matches, m.err = matched(rc)
+	rc := m.ExecStringFrom(subject, start, flags)
+	m.matches, m.err = matched(rc, m.ovector)
 	m.partial = (rc == ERROR_PARTIAL)
 	return m.matches
 }
@@ -322,8 +653,20 @@ func (m *Matcher) MatchString(subject string, flags int) bool {
 // Exec tries to match the specified byte slice to
 // the current pattern. Returns the raw pcre_exec error code.
 func (m *Matcher) Exec(subject []byte, flags int) int {
-	if m.re.ptr == nil {
-		panic("Matcher.Exec: uninitialized")
+	return m.ExecFrom(subject, 0, flags)
+}
+
+// ExecString tries to match the specified subject string to
+// the current pattern. It returns the raw pcre_exec error code.
+func (m *Matcher) ExecString(subject string, flags int) int {
+	return m.ExecStringFrom(subject, 0, flags)
+}
+
+// ExecFrom is like Exec, but passes start as the starting offset
+// to pcre_exec.  Returns the raw pcre_exec error code.
+func (m *Matcher) ExecFrom(subject []byte, start, flags int) int {
+	if m.re.c == nil {
+		panic("Matcher.ExecFrom: uninitialized")
 	}
 	length := len(subject)
 	m.subjects = ""
@@ -332,14 +675,14 @@ func (m *Matcher) Exec(subject []byte, flags int) int {
 		subject = nullbyte // make first character adressable
 	}
 	subjectptr := (*C.char)(unsafe.Pointer(&subject[0]))
-	return m.exec(subjectptr, length, flags)
+	return m.exec(subjectptr, length, start, flags)
 }
 
-// ExecString tries to match the specified subject string to
-// the current pattern. It returns the raw pcre_exec error code.
-func (m *Matcher) ExecString(subject string, flags int) int {
-	if m.re.ptr == nil {
-		panic("Matcher.ExecString: uninitialized")
+// ExecStringFrom is like ExecString, but passes start as the starting
+// offset to pcre_exec.  It returns the raw pcre_exec error code.
+func (m *Matcher) ExecStringFrom(subject string, start, flags int) int {
+	if m.re.c == nil {
+		panic("Matcher.ExecStringFrom: uninitialized")
 	}
 	length := len(subject)
 	m.subjects = subject
@@ -349,30 +692,89 @@ func (m *Matcher) ExecString(subject string, flags int) int {
 	}
 	// The following is a non-portable kludge to avoid a copy
 	subjectptr := *(**C.char)(unsafe.Pointer(&subject))
-	return m.exec(subjectptr, length, flags)
+	return m.exec(subjectptr, length, start, flags)
 }
 
-func (m *Matcher) exec(subjectptr *C.char, length, flags int) int {
-	rc := C.pcre_exec(m.re.ptr, m.re.extra,
-		subjectptr, C.int(length),
-		0, C.int(flags), &m.ovector[0], C.int(len(m.ovector)))
+func (m *Matcher) exec(subjectptr *C.char, length, start, flags int) int {
+	if !m.re.c.acquire() {
+		return ERROR_NULL
+	}
+	defer m.re.c.release()
+	var data *C.go_pcre_callout_data
+	if m.callout != nil || m.watch != nil {
+		data = m.watch
+		if data == nil {
+			data = newCalloutData()
+		}
+		if m.callout != nil {
+			handle := registerCallout(m)
+			defer unregisterCallout(handle)
+			data.handle = C.uintptr_t(handle)
+		}
+	}
+	matchLimit, recursionLimit := m.limits()
+	stack, pool, err := m.stack()
+	if err != nil {
+		return ERROR_NOMEMORY
+	}
+	if pool != nil {
+		defer pool.Put(stack)
+	}
+	// A watched match of a pattern without callouts cannot notice
+	// cancellation while it runs.  It is run with a small match
+	// limit instead, and restarted with twice the limit until the
+	// real one is reached, so that it can be stopped between runs.
+	// The restarts at most double the work of the match.
+	limit := matchLimit
+	sliced := m.watch != nil && m.callout == nil && m.re.c.options&AUTO_CALLOUT == 0
+	if sliced {
+		if matchLimit == 0 {
+			matchLimit = defaultMatchLimit
+		}
+		limit = minInt(watchedMatchLimit, matchLimit)
+	}
+	var rc C.int
+	for {
+		rc = C.go_pcre_exec(m.re.c.ptr, m.re.c.extra,
+			subjectptr, C.int(length),
+			C.int(start), C.int(flags), &m.ovector[0], C.int(len(m.ovector)),
+			data, C.ulong(limit), C.ulong(recursionLimit), stack.cptr(),
+			&m.markptr)
+		if !sliced || rc != C.PCRE_ERROR_MATCHLIMIT || limit >= matchLimit {
+			break
+		}
+		// go_pcre_exec fails at once if the match was canceled.
+		limit = minInt(2*limit, matchLimit)
+	}
+	runtime.KeepAlive(stack)
+	// The mark points into the compiled pattern; copy it while
+	// the pattern is held.
+	m.mark = ""
+	if m.markptr != nil {
+		m.mark = C.GoString((*C.char)(unsafe.Pointer(m.markptr)))
+		m.markptr = nil
+	}
 	return int(rc)
 }
 
+func newCalloutData() *C.go_pcre_callout_data {
+	return new(C.go_pcre_callout_data)
+}
+
+// codeCanceled is the result of a match abandoned by a watched context.
+const codeCanceled = C.GO_PCRE_ERROR_CANCELED
+
 // matched checks the return code of a pattern match for success.
-func matched(rc int) (bool, error) {
+// Failures are reported as a *MatchError; ovector holds the offsets
+// set by the match.
+func matched(rc int, ovector []C.int) (bool, error) {
 	switch {
 	case rc >= 0 || rc == C.PCRE_ERROR_PARTIAL:
 		return true, nil
 	case rc == C.PCRE_ERROR_NOMATCH:
 		return false, nil
-	case rc == C.PCRE_ERROR_BADOPTION:
-		return false, errors.New("PCRE.Match: invalid option flag")
 	}
-	err := errors.New(
-		"unexpected return code from pcre_exec: " + strconv.Itoa(rc),
-	)
-	return false, err
+	return false, newMatchError(rc, ovector)
 }
 
 // Matches returns true if a previous call to Matcher, MatcherString, Reset,
@@ -387,6 +789,14 @@ func (m *Matcher) Partial() bool {
 	return m.partial
 }
 
+// Mark returns the name of the last (*MARK:NAME), (*PRUNE:NAME) or
+// (*THEN:NAME) passed on the matching path by the last match, or an
+// empty string if there is none.  After a failed match, it is the
+// last name passed by the last match attempt.
+func (m *Matcher) Mark() string {
+	return m.mark
+}
+
 // Groups returns the number of groups in the current pattern.
 func (m *Matcher) Groups() int {
 	return m.groups
@@ -496,20 +906,43 @@ func (m *Matcher) Index() (loc []int) {
 
 // name2index converts a group name to its group index number.
 func (m *Matcher) name2index(name string) (int, error) {
-	if m.re.ptr == nil {
+	if m.re.c == nil {
 		return 0, fmt.Errorf("Matcher.Named: uninitialized")
 	}
+	if !m.re.c.acquire() {
+		return 0, ErrFreed
+	}
+	defer m.re.c.release()
 	name1 := C.CString(name)
 	defer C.free(unsafe.Pointer(name1))
-	group := int(C.pcre_get_stringnumber(m.re.ptr, name1))
-	if group < 0 {
-		return group, fmt.Errorf("Matcher.Named: unknown name: " + name)
+	var first, last *C.char
+	size := int(C.pcre_get_stringtable_entries(m.re.c.ptr, name1,
+		&first, &last))
+	if size < 0 {
+		return size, fmt.Errorf("Matcher.Named: unknown name: " + name)
+	}
+	// Several groups have the name with DUPNAMES.  Like
+	// pcre_get_named_substring, pick the first one that is set
+	// by the last match, or else the first one.
+	count := int(uintptr(unsafe.Pointer(last))-
+		uintptr(unsafe.Pointer(first)))/size + 1
+	group := -1
+	for i := 0; i < count; i++ {
+		n := entryGroup(unsafe.Pointer(uintptr(unsafe.Pointer(first)) +
+			uintptr(i*size)))
+		if group < 0 {
+			group = n
+		}
+		if m.matches && m.Present(n) {
+			return n, nil
+		}
 	}
 	return group, nil
 }
 
 // Named returns the value of the named capture group.
 // This is a nil slice if the capture group is not present.
+// If several groups have the name, the first one that is set is used.
 // If the name does not refer to a group then error is non-nil.
 func (m *Matcher) Named(group string) ([]byte, error) {
 	groupNum, err := m.name2index(group)
@@ -543,8 +976,9 @@ func (m *Matcher) NamedPresent(group string) (bool, error) {
 // FindIndex returns the start and end of the first match,
 // or nil if no match.  loc[0] is the start and loc[1] is the end.
 func (re *Regexp) FindIndex(bytes []byte, flags int) (loc []int) {
-	m := re.Matcher(bytes, flags)
-	if m.Matches() {
+	m := re.getMatcher()
+	defer re.putMatcher(m)
+	if m.Match(bytes, flags) {
 		loc = []int{int(m.ovector[0]), int(m.ovector[1])}
 		return
 	}
@@ -553,15 +987,52 @@ func (re *Regexp) FindIndex(bytes []byte, flags int) (loc []int) {
 
 // ReplaceAll returns a copy of a byte slice
 // where all pattern matches are replaced by repl.
+// An empty match immediately following a previous match is not replaced.
 func (re Regexp) ReplaceAll(bytes, repl []byte, flags int) ([]byte, error) {
-	m := re.Matcher(bytes, flags)
+	m := re.getMatcher()
+	defer re.putMatcher(m)
+	return m.replaceAll(bytes, repl, flags)
+}
+
+func (m *Matcher) replaceAll(bytes, repl []byte, flags int) ([]byte, error) {
+	return m.replaceAllFunc(bytes, flags, func(dst []byte) ([]byte, error) {
+		return append(dst, repl...), nil
+	})
+}
+
+// replaceAllFunc is like replaceAll, but repl appends the replacement
+// of the current match of m to dst.  If repl fails, replaceAllFunc
+// stops and returns its error.
+func (m *Matcher) replaceAllFunc(bytes []byte, flags int, repl func(dst []byte) ([]byte, error)) ([]byte, error) {
 	r := []byte{}
-	for m.matches {
-		r = append(append(r, bytes[:m.ovector[0]]...), repl...)
-		bytes = bytes[m.ovector[1]:]
-		m.Match(bytes, flags)
+	last := 0
+	st := m.re.stepper(flags)
+	checked := false // a search has found bytes valid
+	for offset := 0; offset <= len(bytes); {
+		f := flags
+		if checked {
+			f |= st.noCheck(bytes, "", offset)
+		}
+		if !m.MatchFrom(bytes, offset, f) {
+			break
+		}
+		checked = true
+		start, end := int(m.ovector[0]), int(m.ovector[1])
+		r = append(r, bytes[last:start]...)
+		if end > last || start == 0 {
+			var err error
+			if r, err = repl(r); err != nil {
+				return nil, err
+			}
+		}
+		last = end
+		if end > offset {
+			offset = end
+		} else {
+			offset = st.step(bytes, "", offset)
+		}
 	}
-	return append(r, bytes...), m.err
+	return append(r, bytes[last:]...), m.err
 }
 
 // ReplaceAllString is equivalent to ReplaceAll with string return type.
@@ -570,6 +1041,40 @@ func (re Regexp) ReplaceAllString(in, repl string, flags int) (string, error) {
 	return string(str), err
 }
 
+// ReplaceAllFunc returns a copy of src in which all matches of the
+// pattern are replaced by the return value of repl applied to the
+// matched text.  Matches are found as by ReplaceAll.
+func (re Regexp) ReplaceAllFunc(src []byte, repl func([]byte) []byte, flags int) ([]byte, error) {
+	return re.ReplaceAllMatchFunc(src, func(m *Matcher) ([]byte, error) {
+		return repl(m.subjectb[m.ovector[0]:m.ovector[1]]), nil
+	}, flags)
+}
+
+// ReplaceAllStringFunc is like ReplaceAllFunc, with strings.
+func (re Regexp) ReplaceAllStringFunc(src string, repl func(string) string, flags int) (string, error) {
+	r, err := re.ReplaceAllMatchFunc([]byte(src), func(m *Matcher) ([]byte, error) {
+		return []byte(repl(src[m.ovector[0]:m.ovector[1]])), nil
+	}, flags)
+	return string(r), err
+}
+
+// ReplaceAllMatchFunc returns a copy of src in which all matches of the
+// pattern are replaced by the return value of repl, which can read the
+// capture groups of the match from m.  Each search sees all of src, so
+// lookbehind assertions see the text before the previous match, and an
+// empty match immediately following a previous match is not replaced,
+// as with ReplaceAll.  If repl returns an error, ReplaceAllMatchFunc
+// stops and returns it.  m is only valid during the call to repl, which
+// must not start matches with it.
+func (re Regexp) ReplaceAllMatchFunc(src []byte, repl func(m *Matcher) ([]byte, error), flags int) ([]byte, error) {
+	m := re.getMatcher()
+	defer re.putMatcher(m)
+	return m.replaceAllFunc(src, flags, func(dst []byte) ([]byte, error) {
+		r, err := repl(m)
+		return append(dst, r...), err
+	})
+}
+
 // Match holds details about a single successful regex match.
 type Match struct {
 	Finding string // Text that was found.
@@ -578,12 +1083,18 @@ type Match struct {
 
 // FindAll finds all instances that match the regex.
 func (re Regexp) FindAll(subject string, flags int) ([]Match, error) {
+	m := re.getMatcher()
+	defer re.putMatcher(m)
+	return m.findAll(subject, flags)
+}
+
+func (m *Matcher) findAll(subject string, flags int) ([]Match, error) {
 	matches := make([]Match, 0)
-	m := re.MatcherString(subject, flags)
-	offset := 0
-	for m.Matches() {
-		leftIdx := int(m.ovector[0]) + offset
-		rightIdx := int(m.ovector[1]) + offset
+	st := m.re.stepper(flags)
+	// After the first search, the subject is known to be valid.
+	for offset, f := 0, flags; m.MatchStringFrom(subject, offset, f); {
+		leftIdx := int(m.ovector[0])
+		rightIdx := int(m.ovector[1])
 		matches = append(
 			matches,
 			Match{
@@ -591,12 +1102,17 @@ func (re Regexp) FindAll(subject string, flags int) ([]Match, error) {
 				[]int{leftIdx, rightIdx},
 			},
 		)
-		offset += maxInt(1, int(m.ovector[1]))
-		if offset < len(subject) {
-			m.MatchString(subject[offset:], flags)
+		if leftIdx == rightIdx {
+			// Step past an empty match, which may lie beyond
+			// offset, so that it is not found again.
+			offset = st.step(nil, subject, rightIdx)
 		} else {
+			offset = rightIdx
+		}
+		if offset >= len(subject) {
 			break
 		}
+		f = flags | st.noCheck(nil, subject, offset)
 	}
 	return matches, m.err
 }
@@ -616,6 +1132,13 @@ func (e *CompileError) Error() string {
 	return e.Pattern + " (" + strconv.Itoa(e.Offset) + "): " + e.Message
 }
 
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
 func maxInt(a, b int) int {
 	if a > b {
 		return a
@@ -1,7 +1,10 @@
 package pcre
 
 import (
+	"errors"
+	"fmt"
 	"reflect"
+	"strconv"
 	"testing"
 )
 
@@ -354,3 +357,228 @@ func verifyMatches(t *testing.T, expected []Match, matches []Match) {
 		}
 	}
 }
+
+func TestMatchFrom(t *testing.T) {
+	re := MustCompile(`\bfoo`, 0)
+	defer re.FreeRegexp()
+	m := re.NewMatcher()
+	if m.MatchStringFrom("foofoo", 1, 0) {
+		t.Error("MatchStringFrom matched inside a word", m.Index())
+	}
+	if !m.MatchStringFrom("foo foo", 1, 0) {
+		t.Fatal("MatchStringFrom failed")
+	}
+	if i := m.Index(); i[0] != 4 || i[1] != 7 {
+		t.Error("MatchStringFrom index", i)
+	}
+	if !m.MatchFrom([]byte("foo foo"), 4, 0) {
+		t.Fatal("MatchFrom failed")
+	}
+	if g := m.GroupString(0); g != "foo" {
+		t.Error("MatchFrom group", g)
+	}
+	if rc := m.ExecStringFrom("foo", 4, 0); rc >= 0 || rc == ERROR_NOMATCH {
+		t.Error("ExecStringFrom out of range", rc)
+	}
+}
+
+func TestFindAllLookbehind(t *testing.T) {
+	re := MustCompile(`\bfoo`, 0)
+	defer re.FreeRegexp()
+	matches, err := re.FindAll("foofoo foo", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyMatches(t, []Match{
+		Match{"foo", []int{0, 3}},
+		Match{"foo", []int{7, 10}},
+	}, matches)
+
+	re2 := MustCompile(`(?<=a)a`, 0)
+	defer re2.FreeRegexp()
+	result, err := re2.ReplaceAll([]byte("aaa"), []byte("X"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result) != "aXX" {
+		t.Error("ReplaceAll lookbehind", string(result))
+	}
+}
+
+func TestFindAllEmptyAfterOffset(t *testing.T) {
+	for pattern, want := range map[string][]Match{
+		`(?m)^`: {{"", []int{0, 0}}, {"", []int{4, 4}}},
+		`(?=c)`: {{"", []int{4, 4}}},
+	} {
+		re := MustCompile(pattern, 0)
+		matches, err := re.FindAll("a b\nc", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		verifyMatches(t, want, matches)
+		re.FreeRegexp()
+	}
+}
+
+func TestReplaceAllEmpty(t *testing.T) {
+	re := MustCompile(`a*`, 0)
+	defer re.FreeRegexp()
+	result, err := re.ReplaceAllString("baaab", "-", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "-b-b-" {
+		t.Error("ReplaceAllString empty matches", result)
+	}
+}
+
+func TestReplaceAllFunc(t *testing.T) {
+	re := MustCompile(`a*`, 0)
+	defer re.FreeRegexp()
+	result, err := re.ReplaceAllFunc([]byte("baaab"), func(b []byte) []byte {
+		return []byte(fmt.Sprintf("<%d>", len(b)))
+	}, 0)
+	if err != nil || string(result) != "<0>b<3>b<0>" {
+		t.Error("ReplaceAllFunc", string(result), err)
+	}
+
+	re2 := MustCompile(`(?<=a)\w`, 0)
+	defer re2.FreeRegexp()
+	s, err := re2.ReplaceAllStringFunc("aab ab", func(s string) string {
+		return "[" + s + "]"
+	}, 0)
+	if err != nil || s != "a[a][b] a[b]" {
+		t.Error("ReplaceAllStringFunc lookbehind", s, err)
+	}
+}
+
+func TestReplaceAllMatchFunc(t *testing.T) {
+	re := MustCompile(`(?<key>\w+)=(?<value>\d+)`, 0)
+	defer re.FreeRegexp()
+	double := func(m *Matcher) ([]byte, error) {
+		key, _ := m.NamedString("key")
+		value, err := strconv.Atoi(m.GroupString(2))
+		if err != nil {
+			return nil, err
+		}
+		return []byte(fmt.Sprintf("%s=%d", key, 2*value)), nil
+	}
+	result, err := re.ReplaceAllMatchFunc([]byte("a=1, b=21"), double, 0)
+	if err != nil || string(result) != "a=2, b=42" {
+		t.Error("ReplaceAllMatchFunc", string(result), err)
+	}
+	result, err = re.ReplaceAllMatchFunc([]byte("a=1, b=99999999999999999999"), double, 0)
+	if err == nil || result != nil {
+		t.Error("ReplaceAllMatchFunc error", string(result), err)
+	}
+}
+
+func TestSubexpNames(t *testing.T) {
+	re := MustCompile(`(?<year>\d{4})-(\d{2})-(?P<day>\d{2})`, 0)
+	defer re.FreeRegexp()
+	names := re.SubexpNames()
+	if !reflect.DeepEqual(names, []string{"", "year", "", "day"}) {
+		t.Error("SubexpNames", names)
+	}
+}
+
+func TestClose(t *testing.T) {
+	re := MustCompileJIT(`(\d)(?<rest>\d+)`, 0, STUDY_JIT_COMPILE)
+	copied := re
+	m := copied.NewMatcher()
+	if !m.MatchString("abc123", 0) {
+		t.Fatal("Matches before Close")
+	}
+	if err := re.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := re.Close(); !errors.Is(err, ErrFreed) {
+		t.Error("second Close", err)
+	}
+	if m.MatchString("abc123", 0) {
+		t.Error("Matches after Close")
+	}
+	if !errors.Is(m.Err(), ErrFreed) {
+		t.Error("Matcher.Err after Close", m.Err())
+	}
+	if _, err := copied.FindAll("abc123", 0); !errors.Is(err, ErrFreed) {
+		t.Error("FindAll on copy after Close", err)
+	}
+	if err := copied.Study(0); !errors.Is(err, ErrFreed) {
+		t.Error("Study after Close", err)
+	}
+	// The group count is kept, but the names need the freed pattern.
+	if g := copied.Groups(); g != 2 {
+		t.Error("Groups after Close", g)
+	}
+	if names := copied.SubexpNames(); names != nil {
+		t.Error("SubexpNames after Close", names)
+	}
+}
+
+func TestCloseConcurrent(t *testing.T) {
+	re := MustCompile(`(\w+)@(\w+)\.com`, 0)
+	done := make(chan bool)
+	for i := 0; i < 8; i++ {
+		go func(re Regexp) {
+			m := re.NewMatcher()
+			for m.MatchString("mail bob@example.com now", 0) {
+				if m.GroupString(1) != "bob" {
+					t.Error("GroupString", m.GroupString(1))
+				}
+			}
+			if !errors.Is(m.Err(), ErrFreed) {
+				t.Error("Err", m.Err())
+			}
+			done <- true
+		}(re)
+	}
+	re.Close()
+	for i := 0; i < 8; i++ {
+		<-done
+	}
+}
+
+func TestDupNames(t *testing.T) {
+	re := MustCompile(`(?<d>\d{4})-\d\d|\d\d/(?<d>\d\d)|(?<x>x)`, DUPNAMES)
+	defer re.FreeRegexp()
+	indices := re.SubexpIndices()
+	want := map[string][]int{"d": {1, 2}, "x": {3}}
+	if !reflect.DeepEqual(indices, want) {
+		t.Error("SubexpIndices", indices)
+	}
+	if names := re.SubexpNames(); !reflect.DeepEqual(names,
+		[]string{"", "d", "d", "x"}) {
+		t.Error("SubexpNames", names)
+	}
+	m := re.NewMatcher()
+	for subject, d := range map[string]string{
+		"2024-05": "2024", "05/17": "17", "x": "",
+	} {
+		m.MatchString(subject, 0)
+		if s, err := m.NamedString("d"); err != nil || s != d {
+			t.Error("NamedString", subject, s, err)
+		}
+	}
+	m.MatchString("x", 0)
+	if ok, _ := m.NamedPresent("d"); ok {
+		t.Error("NamedPresent")
+	}
+	if _, err := m.Named("y"); err == nil {
+		t.Error("unknown name")
+	}
+}
+
+func TestMark(t *testing.T) {
+	re := MustCompile(`a(*MARK:A)b|c(*:C)(*FAIL)|d`, 0)
+	defer re.FreeRegexp()
+	m := re.NewMatcher()
+	for subject, mark := range map[string]string{
+		"ab": "A", "xd": "", "c": "C",
+	} {
+		m.MatchString(subject, 0)
+		if m.Mark() != mark {
+			t.Errorf("Mark after %q = %q, want %q", subject, m.Mark(), mark)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package pcre
+
+// #include <string.h>
+// #include "./config.h"
+// #include "./pcre_internal.h"
+// // go_pcre_load copies a saved pattern and its study data, if any,
+// // into memory owned by PCRE, converting them to host byte order.
+// // It returns 0 or a PCRE error code.
+// static int go_pcre_load(const void *data, size_t size,
+//         const void *study, size_t study_size,
+//         pcre **code, pcre_extra **extra) {
+//     REAL_PCRE *re;
+//     pcre_extra *e = NULL;
+//     int rc;
+//     if (size < sizeof(REAL_PCRE))
+//         return PCRE_ERROR_BADMAGIC;
+//     if (study_size != 0 && study_size != sizeof(pcre_study_data))
+//         return PCRE_ERROR_BADMAGIC;
+//     re = pcre_malloc(size);
+//     if (re == NULL)
+//         return PCRE_ERROR_NOMEMORY;
+//     memcpy(re, data, size);
+//     if (study_size != 0) {
+//         e = pcre_malloc(sizeof(pcre_extra) + study_size);
+//         if (e == NULL) {
+//             pcre_free(re);
+//             return PCRE_ERROR_NOMEMORY;
+//         }
+//         memset(e, 0, sizeof(pcre_extra));
+//         e->flags = PCRE_EXTRA_STUDY_DATA;
+//         e->study_data = (char *)e + sizeof(pcre_extra);
+//         memcpy(e->study_data, study, study_size);
+//     }
+//     rc = pcre_pattern_to_host_byte_order((pcre *)re, e, NULL);
+//     if (rc == 0 && (re->flags & PCRE_MODE8) == 0)
+//         rc = PCRE_ERROR_BADMODE;
+//     if (rc == 0 && re->size != size)
+//         rc = PCRE_ERROR_BADMAGIC;
+//     if (rc == 0 && e != NULL &&
+//             ((pcre_study_data *)e->study_data)->size != study_size)
+//         rc = PCRE_ERROR_BADMAGIC;
+//     if (rc != 0) {
+//         pcre_free(re);
+//         if (e != NULL)
+//             pcre_free(e);
+//         return rc;
+//     }
+//     *code = (pcre *)re;
+//     *extra = e;
+//     return 0;
+// }
+import "C"
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"unsafe"
+)
+
+// Headers of the encodings of MarshalBinary and MarshalBundle.
+const (
+	binaryMagic   = "GOPCRE"
+	bundleMagic   = "GOPCREBUNDLE"
+	binaryVersion = 1
+	bundleVersion = 1
+)
+
+// Byte order marks of a bundle.
+const (
+	littleEndian = 'L'
+	bigEndian    = 'B'
+)
+
+// ErrBadBinary is returned when loading data that does not hold
+// patterns saved by MarshalBinary or MarshalBundle.
+var ErrBadBinary = errors.New("pcre: invalid compiled pattern data")
+
+// MarshalBinary saves the compiled pattern and its study data, so that
+// UnmarshalBinary can restore it without compiling it again.  The
+// limits and JIT stacks set on the Regexp are not saved.
+func (re Regexp) MarshalBinary() ([]byte, error) {
+	if re.c == nil {
+		panic("Regexp.MarshalBinary: uninitialized")
+	}
+	if !re.c.acquire() {
+		return nil, ErrFreed
+	}
+	defer re.c.release()
+	code := C.GoBytes(unsafe.Pointer(re.c.ptr), C.int(pcreSize(re.c.ptr)))
+	var study []byte
+	if extra := re.c.extra; extra != nil &&
+		extra.flags&C.PCRE_EXTRA_STUDY_DATA != 0 {
+		var size C.size_t
+		C.pcre_fullinfo(re.c.ptr, extra,
+			C.PCRE_INFO_STUDYSIZE, unsafe.Pointer(&size))
+		study = C.GoBytes(extra.study_data, C.int(size))
+	}
+	b := make([]byte, 0, len(binaryMagic)+13+len(code)+len(study))
+	b = append(b, binaryMagic...)
+	b = append(b, binaryVersion)
+	b = appendUint32(b, uint32(re.c.studyFlags))
+	b = appendUint32(b, uint32(len(code)))
+	b = append(b, code...)
+	b = appendUint32(b, uint32(len(study)))
+	b = append(b, study...)
+	return b, nil
+}
+
+// UnmarshalBinary restores a pattern saved by MarshalBinary, possibly
+// on a machine of another byte order.  PCRE checks the magic number of
+// the pattern and converts it to host byte order.  Patterns that were
+// JIT-compiled are studied again, as JIT code cannot be saved.
+func (re *Regexp) UnmarshalBinary(data []byte) error {
+	d := decoder{data: data}
+	if string(d.next(len(binaryMagic))) != binaryMagic {
+		return ErrBadBinary
+	}
+	if v := d.next(1); v == nil || v[0] != binaryVersion {
+		return fmt.Errorf("%w: unsupported version", ErrBadBinary)
+	}
+	studyFlags := int(d.uint32())
+	code := d.next(int(d.uint32()))
+	study := d.next(int(d.uint32()))
+	if d.err || len(d.data) != 0 || len(code) == 0 {
+		return ErrBadBinary
+	}
+	jit := studyFlags&(STUDY_JIT_COMPILE|STUDY_JIT_PARTIAL_SOFT_COMPILE|
+		STUDY_JIT_PARTIAL_HARD_COMPILE) != 0
+	if jit {
+		// Study again below rather than restore the study data.
+		study = nil
+	}
+	var ptr *C.pcre
+	var extra *C.pcre_extra
+	var studyptr unsafe.Pointer
+	if len(study) > 0 {
+		studyptr = unsafe.Pointer(&study[0])
+	}
+	rc := C.go_pcre_load(unsafe.Pointer(&code[0]), C.size_t(len(code)),
+		studyptr, C.size_t(len(study)), &ptr, &extra)
+	if rc != 0 {
+		return fmt.Errorf("%w: %v", ErrBadBinary, newMatchError(int(rc), nil))
+	}
+	c := newCode(ptr)
+	c.extra = extra
+	c.studyFlags = studyFlags
+	loaded := Regexp{c}
+	if jit {
+		if err := loaded.Study(studyFlags); err != nil {
+			loaded.Close()
+			return err
+		}
+	}
+	*re = loaded
+	return nil
+}
+
+// MarshalBundle saves several compiled patterns in one block of data,
+// which starts with a version and the byte order of the patterns, and
+// ends with a CRC-32 checksum.
+func MarshalBundle(res []Regexp) ([]byte, error) {
+	b := append([]byte(bundleMagic), bundleVersion, hostByteOrder())
+	b = appendUint32(b, uint32(len(res)))
+	for i, re := range res {
+		data, err := re.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("pcre: pattern %d: %w", i, err)
+		}
+		b = appendUint32(b, uint32(len(data)))
+		b = append(b, data...)
+	}
+	return appendUint32(b, crc32.ChecksumIEEE(b)), nil
+}
+
+// UnmarshalBundle restores the patterns saved by MarshalBundle, after
+// verifying the checksum of the bundle.
+func UnmarshalBundle(data []byte) ([]Regexp, error) {
+	if len(data) < len(bundleMagic)+10 ||
+		string(data[:len(bundleMagic)]) != bundleMagic {
+		return nil, ErrBadBinary
+	}
+	body, sum := data[:len(data)-4], data[len(data)-4:]
+	if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(sum) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrBadBinary)
+	}
+	d := decoder{data: body[len(bundleMagic):]}
+	header := d.next(2)
+	if header[0] != bundleVersion {
+		return nil, fmt.Errorf("%w: unsupported version", ErrBadBinary)
+	}
+	if header[1] != littleEndian && header[1] != bigEndian {
+		return nil, fmt.Errorf("%w: unknown byte order", ErrBadBinary)
+	}
+	count := int(d.uint32())
+	if count > len(d.data)/4 {
+		return nil, ErrBadBinary
+	}
+	res := make([]Regexp, count)
+	for i := range res {
+		entry := d.next(int(d.uint32()))
+		if d.err {
+			break
+		}
+		if err := res[i].UnmarshalBinary(entry); err != nil {
+			freeAll(res[:i])
+			return nil, fmt.Errorf("pcre: pattern %d: %w", i, err)
+		}
+	}
+	if d.err || len(d.data) != 0 {
+		freeAll(res)
+		return nil, ErrBadBinary
+	}
+	return res, nil
+}
+
+func freeAll(res []Regexp) {
+	for i := range res {
+		res[i].Close()
+	}
+}
+
+// hostByteOrder returns the byte order mark of this machine.
+func hostByteOrder() byte {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return littleEndian
+	}
+	return bigEndian
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+// decoder reads the fields of encoded patterns.  Reading past the end
+// of the data sets err and returns nil.
+type decoder struct {
+	data []byte
+	err  bool
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err || n < 0 || n > len(d.data) {
+		d.err = true
+		return nil
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) uint32() uint32 {
+	b := d.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
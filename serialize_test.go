@@ -0,0 +1,154 @@
+package pcre
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalBinary(t *testing.T) {
+	for _, study := range []int{-1, STUDY_EXTRA_NEEDED, STUDY_JIT_COMPILE} {
+		re := MustCompile(`(?<word>\w+)@(?<host>[a-z]+\.com)`, CASELESS)
+		if study >= 0 {
+			if err := re.Study(study); err != nil {
+				t.Fatal(err)
+			}
+		}
+		data, err := re.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var loaded Regexp
+		if err := loaded.UnmarshalBinary(data); err != nil {
+			t.Fatal(study, err)
+		}
+		want, _ := re.Info()
+		got, _ := loaded.Info()
+		// The JIT compiler may generate code of another size.
+		got.JITSize, want.JITSize = 0, 0
+		if got != want {
+			t.Errorf("study %d: Info %+v, want %+v", study, got, want)
+		}
+		if names := loaded.SubexpNames(); !reflect.DeepEqual(names,
+			[]string{"", "word", "host"}) {
+			t.Error("SubexpNames", names)
+		}
+		m := loaded.MatcherString("mail Joe@Example.COM", 0)
+		if host, _ := m.NamedString("host"); host != "Example.COM" {
+			t.Error("match", host, m.Err())
+		}
+		re.Close()
+		loaded.Close()
+	}
+}
+
+func TestUnmarshalBinaryInvalid(t *testing.T) {
+	re := MustCompile(`abc`, 0)
+	defer re.FreeRegexp()
+	data, _ := re.MarshalBinary()
+	var loaded Regexp
+	for _, bad := range [][]byte{
+		nil,
+		data[:len(data)-1],
+		append(append([]byte{}, data...), 0),
+		[]byte("GOPCRE\x01\x00\x00\x00\x00\x04\x00\x00\x00abcd\x00\x00\x00\x00"),
+	} {
+		if err := loaded.UnmarshalBinary(bad); !errors.Is(err, ErrBadBinary) {
+			t.Error("accepted", bad, err)
+		}
+	}
+	corrupt := append([]byte{}, data...)
+	corrupt[len(binaryMagic)+9] ^= 0xff // magic number of the pattern
+	if err := loaded.UnmarshalBinary(corrupt); !errors.Is(err, ErrBadBinary) {
+		t.Error("bad magic", err)
+	}
+	if loaded.c != nil {
+		t.Error("failed loads changed the Regexp")
+	}
+}
+
+func TestBundle(t *testing.T) {
+	patterns := []string{`\d+`, `(?<a>x)(?<b>y)?`, `^foo$`}
+	var res []Regexp
+	for _, p := range patterns {
+		res = append(res, MustCompileJIT(p, MULTILINE, STUDY_JIT_COMPILE))
+	}
+	data, err := MarshalBundle(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := UnmarshalBundle(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != len(patterns) {
+		t.Fatal("loaded", len(loaded), "patterns")
+	}
+	for i, subject := range []string{"abc 42", "xy", "bar\nfoo\n"} {
+		want := res[i].MatcherString(subject, 0).ExtractString()
+		got := loaded[i].MatcherString(subject, 0).ExtractString()
+		if !reflect.DeepEqual(got, want) {
+			t.Error(patterns[i], got, want)
+		}
+		if info, _ := loaded[i].Info(); !info.JIT {
+			t.Error(patterns[i], "not JIT-compiled on load")
+		}
+	}
+
+	data[len(data)/2] ^= 1
+	if _, err := UnmarshalBundle(data); !errors.Is(err, ErrBadBinary) {
+		t.Error("corrupt bundle", err)
+	}
+	res[0].Close()
+	if _, err := MarshalBundle(res); !errors.Is(err, ErrFreed) {
+		t.Error("freed pattern", err)
+	}
+}
+
+// swapPattern converts a saved pattern to the other byte order, as
+// saved on a machine of that byte order.
+func swapPattern(data []byte) []byte {
+	data = append([]byte{}, data...)
+	swap := func(b []byte) {
+		for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+			b[i], b[j] = b[j], b[i]
+		}
+	}
+	code := data[len(binaryMagic)+9:]
+	// The header of a compiled pattern has six 32-bit fields followed
+	// by twelve 16-bit fields.
+	for i := 0; i < 24; i += 4 {
+		swap(code[i : i+4])
+	}
+	for i := 24; i < 48; i += 2 {
+		swap(code[i : i+2])
+	}
+	// The study data has 32-bit size, flags and minimum length fields.
+	study := data[len(data)-44:]
+	for _, i := range []int{0, 4, 40} {
+		swap(study[i : i+4])
+	}
+	return data
+}
+
+func TestUnmarshalBinaryByteOrder(t *testing.T) {
+	re := MustCompile(`(?<y>\d{4})-(?<m>\d\d)`, 0)
+	defer re.FreeRegexp()
+	if err := re.Study(STUDY_EXTRA_NEEDED); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := re.MarshalBinary()
+	var loaded Regexp
+	if err := loaded.UnmarshalBinary(swapPattern(data)); err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.FreeRegexp()
+	want, _ := re.Info()
+	if got, _ := loaded.Info(); got != want || got.MinLength != 7 {
+		t.Errorf("Info %+v, want %+v", got, want)
+	}
+	m := loaded.MatcherString("on 2024-05-17", 0)
+	if y, _ := m.NamedString("y"); y != "2024" {
+		t.Error("match", y, m.Err())
+	}
+}
@@ -0,0 +1,366 @@
+package pcre
+
+import (
+	"errors"
+	"strconv"
+)
+
+// RegexpSet matches a subject against many patterns at once.  The
+// patterns are compiled into a single alternation, in which each
+// alternative starts with a (*MARK) naming its pattern, so that one
+// search finds the leftmost match of any pattern and tells which
+// pattern matched.  At the leftmost position, patterns earlier in the
+// set take precedence.  The alternation uses a branch reset group, so
+// the capture groups of each pattern keep their numbers.
+//
+// Backtracking control verbs such as (*COMMIT) and (*ACCEPT), marks,
+// and recursion into the whole pattern with (?R) would act on the whole
+// alternation rather than on their own pattern, so sets with patterns
+// using them are not combined.  Neither is the alternation if it cannot
+// be compiled, for example because it exceeds the size limit of a
+// compiled pattern or because the patterns use conflicting group
+// names.  The set then matches each pattern separately and picks the
+// leftmost match, which gives the same results more slowly.
+//
+// MatchAll reports every pattern that matches, rather than the leftmost
+// match, by matching each pattern on its own.
+type RegexpSet struct {
+	ids      []string
+	groups   []int    // number of capture groups of each pattern
+	combined Regexp   // the alternation, c is nil if not compiled
+	patterns []Regexp // each pattern compiled on its own
+}
+
+// SetMatch describes a match of a RegexpSet.
+type SetMatch struct {
+	ID      string // ID of the matching pattern
+	Pattern int    // Position of the matching pattern in the set
+	// Loc holds the index pairs of the match and of the capture
+	// groups of the pattern, as returned by FindSubmatchIndex.
+	Loc []int
+}
+
+// CompileSet compiles the patterns into a RegexpSet, with the given
+// compile flags.  ids holds an identifier for each pattern, which is
+// reported by its matches.  If a pattern fails to compile, the second
+// return value holds its *CompileError.
+func CompileSet(ids, patterns []string, flags int) (*RegexpSet, error) {
+	if len(ids) != len(patterns) {
+		return nil, errors.New("pcre: CompileSet: number of ids and patterns differ")
+	}
+	s := &RegexpSet{
+		ids:      append([]string(nil), ids...),
+		groups:   make([]int, len(patterns)),
+		patterns: make([]Regexp, 0, len(patterns)),
+	}
+	for i, pattern := range patterns {
+		re, err := Compile(pattern, flags)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		s.groups[i] = re.Groups()
+		s.patterns = append(s.patterns, re)
+	}
+	if len(patterns) == 0 {
+		return s, nil
+	}
+	for _, pattern := range patterns {
+		if !combinable(pattern) {
+			return s, nil
+		}
+	}
+	if combined, err := Compile(combinePatterns(patterns), flags); err == nil {
+		s.combined = combined
+	}
+	return s, nil
+}
+
+// combineBlockers are the pattern texts that keep a set from being
+// combined: control verbs and marks, which would act on the whole
+// alternation, and recursion and subroutine calls, which would call
+// the whole alternation or the groups of another pattern, since the
+// group numbers restart in each branch.  Finding them in escaped or
+// quoted text, or in option settings such as (?-i), only costs the
+// speed of the alternation.
+var combineBlockers = []string{
+	"(*ACCEPT", "(*COMMIT", "(*PRUNE", "(*SKIP", "(*THEN",
+	"(*MARK", "(*:", "(?R)", "(?0)",
+	"(?1", "(?2", "(?3", "(?4", "(?5", "(?6", "(?7", "(?8", "(?9",
+	"(?+", "(?-", "(?&", "(?P>", `\g<`, `\g'`,
+}
+
+// combinable reports whether pattern may be part of the alternation of
+// a RegexpSet.
+func combinable(pattern string) bool {
+	for _, blocker := range combineBlockers {
+		for i := 0; i+len(blocker) <= len(pattern); i++ {
+			if pattern[i:i+len(blocker)] == blocker {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// combinePatterns builds the alternation of a RegexpSet.  Each pattern
+// is followed by \E, which ends an unterminated \Q but is otherwise
+// ignored.
+func combinePatterns(patterns []string) string {
+	b := []byte("(?|")
+	for i, pattern := range patterns {
+		if i > 0 {
+			b = append(b, '|')
+		}
+		b = append(b, "(*MARK:"...)
+		b = append(b, strconv.Itoa(i)...)
+		b = append(b, ")(?:"...)
+		b = append(b, pattern...)
+		b = append(b, `\E)`...)
+	}
+	return string(append(b, ')'))
+}
+
+// MustCompileSet compiles the patterns into a RegexpSet.  If
+// compilation fails, panic.
+func MustCompileSet(ids, patterns []string, flags int) *RegexpSet {
+	s, err := CompileSet(ids, patterns, flags)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Len returns the number of patterns in the set.
+func (s *RegexpSet) Len() int {
+	return len(s.ids)
+}
+
+// Combined reports whether the patterns were compiled into a single
+// alternation, rather than being matched separately.
+func (s *RegexpSet) Combined() bool {
+	return s.combined.c != nil
+}
+
+// Study studies the compiled patterns, as Regexp.Study does.
+// Study must not be called while the set is in use by other goroutines.
+func (s *RegexpSet) Study(flags int) error {
+	if s.combined.c != nil {
+		if err := s.combined.Study(flags); err != nil {
+			return err
+		}
+	}
+	for i := range s.patterns {
+		if err := s.patterns[i].Study(flags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close frees the C memory of the compiled patterns.
+func (s *RegexpSet) Close() error {
+	var err error
+	if s.combined.c != nil {
+		err = s.combined.Close()
+	}
+	for i := range s.patterns {
+		s.patterns[i].Close()
+	}
+	s.patterns = nil
+	return err
+}
+
+// matchers returns the matchers for a search of the set.  Return them
+// with putMatchers when done.
+func (s *RegexpSet) matchers() []*Matcher {
+	if s.combined.c != nil {
+		return []*Matcher{s.combined.getMatcher()}
+	}
+	ms := make([]*Matcher, len(s.patterns))
+	for i, re := range s.patterns {
+		ms[i] = re.getMatcher()
+	}
+	return ms
+}
+
+func (s *RegexpSet) putMatchers(ms []*Matcher) {
+	if s.combined.c != nil {
+		s.combined.putMatcher(ms[0])
+		return
+	}
+	for i, re := range s.patterns {
+		re.putMatcher(ms[i])
+	}
+}
+
+// next finds the leftmost match of the set at or after start.  match
+// runs a matcher at an offset of the subject.
+func (s *RegexpSet) next(ms []*Matcher, start int, match func(m *Matcher, start int) bool) (*SetMatch, error) {
+	if s.combined.c != nil {
+		m := ms[0]
+		if !match(m, start) {
+			return nil, m.Err()
+		}
+		i, err := strconv.Atoi(m.Mark())
+		if err != nil || i < 0 || i >= len(s.ids) {
+			return nil, errors.New("pcre: RegexpSet: unexpected mark " +
+				strconv.Quote(m.Mark()))
+		}
+		return s.setMatch(m, i), nil
+	}
+	var best *SetMatch
+	for i, m := range ms {
+		if !match(m, start) {
+			if err := m.Err(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if best == nil || int(m.ovector[0]) < best.Loc[0] {
+			best = s.setMatch(m, i)
+		}
+	}
+	return best, nil
+}
+
+func (s *RegexpSet) setMatch(m *Matcher, i int) *SetMatch {
+	return &SetMatch{
+		ID:      s.ids[i],
+		Pattern: i,
+		Loc:     m.submatchIndex()[:2*(s.groups[i]+1)],
+	}
+}
+
+// Match returns the leftmost match of the set in subject, or nil if
+// there is none.
+func (s *RegexpSet) Match(subject []byte, flags int) (*SetMatch, error) {
+	ms := s.matchers()
+	defer s.putMatchers(ms)
+	return s.next(ms, 0, func(m *Matcher, start int) bool {
+		return m.MatchFrom(subject, start, flags)
+	})
+}
+
+// MatchString is like Match, with a string subject.
+func (s *RegexpSet) MatchString(subject string, flags int) (*SetMatch, error) {
+	ms := s.matchers()
+	defer s.putMatchers(ms)
+	return s.next(ms, 0, func(m *Matcher, start int) bool {
+		return m.MatchStringFrom(subject, start, flags)
+	})
+}
+
+// MatchAll returns the leftmost match of each pattern of the set that
+// matches subject, in the order of the patterns in the set.  Unlike
+// FindAll, the matches may overlap.  If the set is combined, a search
+// with the alternation first rules out subjects that match none of
+// the patterns.
+func (s *RegexpSet) MatchAll(subject []byte, flags int) ([]SetMatch, error) {
+	return s.matchAll(func(m *Matcher) bool {
+		return m.MatchFrom(subject, 0, flags)
+	})
+}
+
+// MatchAllString is like MatchAll, with a string subject.
+func (s *RegexpSet) MatchAllString(subject string, flags int) ([]SetMatch, error) {
+	return s.matchAll(func(m *Matcher) bool {
+		return m.MatchStringFrom(subject, 0, flags)
+	})
+}
+
+func (s *RegexpSet) matchAll(match func(m *Matcher) bool) ([]SetMatch, error) {
+	if s.combined.c != nil {
+		m := s.combined.getMatcher()
+		ok, err := match(m), m.Err()
+		s.combined.putMatcher(m)
+		if !ok {
+			return nil, err
+		}
+	}
+	var result []SetMatch
+	for i, re := range s.patterns {
+		m := re.getMatcher()
+		if match(m) {
+			result = append(result, *s.setMatch(m, i))
+		}
+		err := m.Err()
+		re.putMatcher(m)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// FindAll returns up to n successive non-overlapping matches of the
+// set in subject, or all of them if n < 0, scanning the subject once.
+// As with Regexp.FindAll, an empty match immediately following a
+// previous match is ignored.  A pattern whose matches all overlap
+// earlier matches of the set is not reported.
+func (s *RegexpSet) FindAll(subject []byte, n, flags int) ([]SetMatch, error) {
+	st := s.stepper(flags)
+	return s.findAll(len(subject), n, func(m *Matcher, start int) bool {
+		return m.MatchFrom(subject, start, flags)
+	}, func(pos int) int {
+		return st.step(subject, "", pos)
+	})
+}
+
+// FindAllString is like FindAll, with a string subject.
+func (s *RegexpSet) FindAllString(subject string, n, flags int) ([]SetMatch, error) {
+	st := s.stepper(flags)
+	return s.findAll(len(subject), n, func(m *Matcher, start int) bool {
+		return m.MatchStringFrom(subject, start, flags)
+	}, func(pos int) int {
+		return st.step(nil, subject, pos)
+	})
+}
+
+// stepper returns the stepper for matches of the set.  Without the
+// alternation, it steps by characters if any pattern is in UTF-8 mode,
+// and over CRLF if it is a newline for any pattern.
+func (s *RegexpSet) stepper(flags int) stepper {
+	if s.combined.c != nil {
+		return s.combined.stepper(flags)
+	}
+	var st stepper
+	for _, re := range s.patterns {
+		p := re.stepper(flags)
+		st.utf = st.utf || p.utf
+		st.crlf = st.crlf || p.crlf
+	}
+	return st
+}
+
+// findAll collects up to n matches of the set.  match runs a matcher at
+// an offset of the subject, and step returns the offset of the
+// character after that of an empty match.
+func (s *RegexpSet) findAll(length, n int, match func(m *Matcher, start int) bool, step func(pos int) int) ([]SetMatch, error) {
+	if n < 0 {
+		n = length + 1
+	}
+	ms := s.matchers()
+	defer s.putMatchers(ms)
+	var result []SetMatch
+	for pos, prevMatchEnd := 0, -1; len(result) < n && pos <= length; {
+		sm, err := s.next(ms, pos, match)
+		if sm == nil {
+			return result, err
+		}
+		accept := true
+		if sm.Loc[1] == pos {
+			if sm.Loc[0] == prevMatchEnd {
+				accept = false
+			}
+			pos = step(pos)
+		} else {
+			pos = sm.Loc[1]
+		}
+		prevMatchEnd = sm.Loc[1]
+		if accept {
+			result = append(result, *sm)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,227 @@
+package pcre
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+var setTests = []struct {
+	subject string
+	want    []SetMatch
+}{
+	{"GET /index.html 200", []SetMatch{
+		{"method", 0, []int{0, 3}},
+		{"path", 2, []int{4, 15, 5, 15}},
+		{"status", 1, []int{16, 19, 16, 17}},
+	}},
+	{"404 POST", []SetMatch{
+		{"status", 1, []int{0, 3, 0, 1}},
+		{"method", 0, []int{4, 8}},
+	}},
+	{"nothing here", nil},
+}
+
+func compileTestSet(t *testing.T, combined bool) *RegexpSet {
+	ids := []string{"method", "status", "path"}
+	patterns := []string{`GET|POST`, `([1-5])\d\d`, `/(\S+)`}
+	if !combined {
+		// A leading (*UTF8) is only valid at the start of a pattern.
+		patterns[0] = "(*UTF8)" + patterns[0]
+	}
+	s, err := CompileSet(ids, patterns, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Combined() != combined {
+		t.Fatalf("Combined() = %v, want %v", s.Combined(), combined)
+	}
+	return s
+}
+
+func TestRegexpSet(t *testing.T) {
+	for _, combined := range []bool{true, false} {
+		s := compileTestSet(t, combined)
+		for _, test := range setTests {
+			got, err := s.FindAllString(test.subject, -1, 0)
+			if err != nil || !reflect.DeepEqual(got, test.want) {
+				t.Errorf("combined %v: FindAllString(%q) = %v, %v, want %v",
+					combined, test.subject, got, err, test.want)
+			}
+			first, err := s.Match([]byte(test.subject), 0)
+			if err != nil {
+				t.Error(err)
+			}
+			if test.want == nil {
+				if first != nil {
+					t.Errorf("Match(%q) = %v, want nil", test.subject, first)
+				}
+			} else if first == nil || !reflect.DeepEqual(*first, test.want[0]) {
+				t.Errorf("Match(%q) = %v, want %v",
+					test.subject, first, test.want[0])
+			}
+		}
+		if err := s.Study(0); err != nil {
+			t.Error(err)
+		}
+		if got, _ := s.MatchString("x 500", 0); got == nil || got.ID != "status" {
+			t.Errorf("after Study: %v", got)
+		}
+		s.Close()
+	}
+}
+
+func TestRegexpSetPrecedence(t *testing.T) {
+	s := MustCompileSet([]string{"word", "key"}, []string{`\w+`, `key`}, 0)
+	defer s.Close()
+	// Both match at the same position; the earlier pattern wins.
+	if m, _ := s.MatchString("key", 0); m == nil || m.ID != "word" {
+		t.Errorf("got %v, want word", m)
+	}
+}
+
+func TestRegexpSetBackrefs(t *testing.T) {
+	s := MustCompileSet([]string{"a", "b"},
+		[]string{`(x)\1`, `(?<q>['"]).*?\k<q>`}, 0)
+	defer s.Close()
+	if !s.Combined() {
+		t.Fatal("not combined")
+	}
+	got, err := s.FindAllString(`xy "z' a" xx`, -1, 0)
+	want := []SetMatch{
+		{"b", 1, []int{3, 9, 3, 4}},
+		{"a", 0, []int{10, 12, 10, 11}},
+	}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, %v, want %v", got, err, want)
+	}
+}
+
+func TestRegexpSetQuoting(t *testing.T) {
+	s := MustCompileSet([]string{"quoted", "plain"},
+		[]string{`\Qa|b`, `c`}, 0)
+	defer s.Close()
+	got, _ := s.FindAllString("a|b c", -1, 0)
+	if len(got) != 2 || got[0].ID != "quoted" || got[1].ID != "plain" {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestRegexpSetControlVerbs(t *testing.T) {
+	for _, test := range []struct {
+		patterns []string
+		subject  string
+		want     *SetMatch
+	}{
+		{[]string{`a(*COMMIT)b`, `c`}, "ac", &SetMatch{"B", 1, []int{1, 2}}},
+		{[]string{`(*MARK:1)a(*ACCEPT)`, `zzz`}, "a", &SetMatch{"A", 0, []int{0, 1}}},
+		{[]string{`a(*SKIP)b|.`, `x`}, "ax", &SetMatch{"A", 0, []int{1, 2}}},
+		{[]string{`\((?:[^()]|(?R))*\)`, `x`}, "x(())", &SetMatch{"B", 1, []int{0, 1}}},
+	} {
+		s := MustCompileSet([]string{"A", "B"}, test.patterns, 0)
+		if s.Combined() {
+			t.Errorf("%q: combined", test.patterns)
+		}
+		got, err := s.MatchString(test.subject, 0)
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: MatchString(%q) = %v, %v, want %v",
+				test.patterns, test.subject, got, err, test.want)
+		}
+		s.Close()
+	}
+}
+
+func TestRegexpSetSubroutines(t *testing.T) {
+	for _, pattern := range []string{
+		`(a)(?1)`, `(a)(?-1)`, `(?+1)(a)`, `(?<p>a)(?&p)`,
+		`(?P<p>a)(?P>p)`, `(a)\g<1>`, `(a)\g'-1'`,
+	} {
+		s := MustCompileSet([]string{"x", "sub"}, []string{`(x)`, pattern}, 0)
+		if s.Combined() {
+			t.Errorf("%s: combined", pattern)
+		}
+		re := MustCompile(pattern, 0)
+		m := re.NewMatcher()
+		if !m.MatchString("aa", 0) {
+			t.Fatalf("%s does not match alone", pattern)
+		}
+		want := SetMatch{"sub", 1, m.submatchIndex()}
+		got, err := s.MatchString("aa", 0)
+		if err != nil || got == nil || !reflect.DeepEqual(*got, want) {
+			t.Errorf("%s: MatchString = %v, %v, want %v", pattern, got, err, want)
+		}
+		all, err := s.MatchAllString("aa", 0)
+		if err != nil || !reflect.DeepEqual(all, []SetMatch{want}) {
+			t.Errorf("%s: MatchAllString = %v, %v, want %v", pattern, all, err, want)
+		}
+		re.Close()
+		s.Close()
+	}
+}
+
+func TestRegexpSetMatchAll(t *testing.T) {
+	for _, combined := range []bool{true, false} {
+		patterns := []string{`error`, `err.*timeout`, `warn`}
+		if !combined {
+			patterns[2] = "(*UTF8)" + patterns[2]
+		}
+		s := MustCompileSet([]string{"error", "timeout", "warn"}, patterns, 0)
+		if s.Combined() != combined {
+			t.Fatalf("Combined() = %v, want %v", s.Combined(), combined)
+		}
+		for subject, want := range map[string][]SetMatch{
+			"error timeout": {
+				{"error", 0, []int{0, 5}},
+				{"timeout", 1, []int{0, 13}},
+			},
+			"warn: error": {
+				{"error", 0, []int{6, 11}},
+				{"warn", 2, []int{0, 4}},
+			},
+			"ok": nil,
+		} {
+			got, err := s.MatchAllString(subject, 0)
+			if err != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("combined %v: MatchAllString(%q) = %v, %v, want %v",
+					combined, subject, got, err, want)
+			}
+			got, err = s.MatchAll([]byte(subject), 0)
+			if err != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("combined %v: MatchAll(%q) = %v, %v, want %v",
+					combined, subject, got, err, want)
+			}
+		}
+		s.Close()
+	}
+}
+
+func TestRegexpSetTooLarge(t *testing.T) {
+	var ids, patterns []string
+	for i := 0; i < 3000; i++ {
+		ids = append(ids, fmt.Sprint("id", i))
+		patterns = append(patterns, fmt.Sprintf("[a-z]{3}%d[0-9]{2}xyzzy", i))
+	}
+	s, err := CompileSet(ids, patterns, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if s.Combined() {
+		t.Error("oversized alternation compiled")
+	}
+	m, err := s.MatchString("..abc299912xyzzy", 0)
+	if err != nil || m == nil || m.ID != "id2999" ||
+		!reflect.DeepEqual(m.Loc, []int{2, 16}) {
+		t.Errorf("got %v, %v", m, err)
+	}
+}
+
+func TestCompileSetError(t *testing.T) {
+	_, err := CompileSet([]string{"a", "b"}, []string{`a`, `(`}, 0)
+	if _, ok := err.(*CompileError); !ok {
+		t.Errorf("got %v, want a *CompileError", err)
+	}
+	if _, err := CompileSet([]string{"a"}, nil, 0); err == nil {
+		t.Error("mismatched ids accepted")
+	}
+}
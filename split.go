@@ -0,0 +1,162 @@
+package pcre
+
+import (
+	"bufio"
+)
+
+// SplitMode selects the tokens produced by Regexp.SplitFunc.
+type SplitMode int
+
+const (
+	// SplitTokens produces the text of each match of the pattern,
+	// dropping the text in between.
+	SplitTokens SplitMode = iota
+	// SplitDelimited produces the text between matches of the
+	// pattern, which act as delimiters.  Text after the last
+	// delimiter is the final token; a trailing delimiter does not
+	// produce an empty final token, as with bufio.ScanLines.
+	SplitDelimited
+)
+
+// SplitFunc returns a bufio.SplitFunc for use with bufio.Scanner that
+// splits its input with the pattern, as selected by mode.  Empty
+// matches are ignored.  While the Scanner may read more data, the
+// pattern is matched with PARTIAL_HARD, so that a match running into
+// the end of the buffer makes the Scanner read more before deciding.
+// Each call sees the input from the end of the previous token, so
+// lookbehind assertions cannot look at text before it.  The returned
+// function holds a Matcher, so use a separate one for each Scanner.
+func (re Regexp) SplitFunc(mode SplitMode) bufio.SplitFunc {
+	m := re.NewMatcher()
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+		flags := NOTEMPTY
+		if !atEOF {
+			flags |= PARTIAL_HARD
+		}
+		m.err = nil
+		if !m.Match(data, flags) {
+			if err := m.Err(); err != nil {
+				return 0, nil, err
+			}
+			switch {
+			case mode == SplitTokens:
+				// No match can start in data.
+				return len(data), nil, nil
+			case atEOF:
+				return len(data), data, nil
+			}
+			return 0, nil, nil
+		}
+		start, end := int(m.ovector[0]), int(m.ovector[1])
+		if m.Partial() {
+			// Wait for the rest of the match.
+			if mode == SplitTokens {
+				return start, nil, nil
+			}
+			return 0, nil, nil
+		}
+		if mode == SplitTokens {
+			return end, data[start:end], nil
+		}
+		return end, data[:start], nil
+	}
+}
+
+// Split slices b into the fields separated by matches of the pattern
+// and returns the fields, as Split of Go's regexp package does.  If n
+// > 0, Split returns at most n fields, the last of which holds the
+// rest of b; if n == 0 it returns nil, and if n < 0 all the fields.
+func (re Regexp) Split(b []byte, n, flags int) [][]byte {
+	return re.splitBytes(b, n, flags, false)
+}
+
+// SplitString is like Split, with strings.
+func (re Regexp) SplitString(s string, n, flags int) []string {
+	return re.splitString(s, n, flags, false)
+}
+
+// SplitSubmatch is like Split, but like Perl's split, the capture
+// groups of each match are returned after the field preceding it.
+// Groups which are not present are nil.  Only fields count towards n.
+func (re Regexp) SplitSubmatch(b []byte, n, flags int) [][]byte {
+	return re.splitBytes(b, n, flags, true)
+}
+
+// SplitStringSubmatch is like SplitSubmatch, with strings.  Groups
+// which are not present are empty strings.
+func (re Regexp) SplitStringSubmatch(s string, n, flags int) []string {
+	return re.splitString(s, n, flags, true)
+}
+
+func (re Regexp) splitBytes(b []byte, n, flags int, submatch bool) [][]byte {
+	if n == 0 {
+		return nil
+	}
+	// As in Go, the empty pattern splits an empty subject into no
+	// fields, and other patterns into one empty field.
+	if len(b) == 0 && !re.c.empty {
+		return [][]byte{{}}
+	}
+	all := re.allIndexBytes(b, n, flags)
+	result := make([][]byte, 0, len(all)+1)
+	splitFields(len(b), n, all, submatch,
+		func(start, end int) {
+			if start < 0 {
+				result = append(result, nil)
+			} else {
+				result = append(result, b[start:end:end])
+			}
+		})
+	return result
+}
+
+func (re Regexp) splitString(s string, n, flags int, submatch bool) []string {
+	if n == 0 {
+		return nil
+	}
+	if len(s) == 0 && !re.c.empty {
+		return []string{""}
+	}
+	all := re.allIndexString(s, n, flags)
+	result := make([]string, 0, len(all)+1)
+	splitFields(len(s), n, all, submatch,
+		func(start, end int) {
+			if start < 0 {
+				result = append(result, "")
+			} else {
+				result = append(result, s[start:end])
+			}
+		})
+	return result
+}
+
+// splitFields calls add with the bounds of the fields of a subject of
+// the given length, split at the matches all, and if submatch is set,
+// with the bounds of the capture groups of each match after the field
+// preceding it.  Groups which are not present have bounds -1.
+func splitFields(length, n int, all [][]int, submatch bool, add func(start, end int)) {
+	fields := 0
+	beg, end := 0, 0
+	for _, loc := range all {
+		if n > 0 && fields == n-1 {
+			break
+		}
+		end = loc[0]
+		if loc[1] != 0 {
+			add(beg, end)
+			fields++
+			if submatch {
+				for i := 2; i < len(loc); i += 2 {
+					add(loc[i], loc[i+1])
+				}
+			}
+		}
+		beg = loc[1]
+	}
+	if end != length {
+		add(beg, length)
+	}
+}
@@ -0,0 +1,112 @@
+package pcre
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"testing"
+	"testing/iotest"
+)
+
+func scanAll(re Regexp, mode SplitMode, input string) ([]string, error) {
+	sc := bufio.NewScanner(iotest.OneByteReader(bytes.NewReader([]byte(input))))
+	sc.Split(re.SplitFunc(mode))
+	var tokens []string
+	for sc.Scan() {
+		tokens = append(tokens, sc.Text())
+	}
+	return tokens, sc.Err()
+}
+
+func TestSplitFunc(t *testing.T) {
+	tests := []struct {
+		pattern string
+		mode    SplitMode
+		input   string
+		want    []string
+	}{
+		{`\d+`, SplitTokens, "a12b345c6", []string{"12", "345", "6"}},
+		{`\d+`, SplitTokens, "no digits", nil},
+		{`"[^"]*"`, SplitTokens, `x "one" y "two three" "unterminated`,
+			[]string{`"one"`, `"two three"`}},
+		{`x*`, SplitTokens, "axxbx", []string{"xx", "x"}},
+		{`\s*,\s*`, SplitDelimited, "a , b,,c  ,  d", []string{"a", "b", "", "c", "d"}},
+		{`\r?\n`, SplitDelimited, "one\r\ntwo\nthree\n", []string{"one", "two", "three"}},
+		{`--+`, SplitDelimited, "a---b--c", []string{"a", "b", "c"}},
+		{`;`, SplitDelimited, "", nil},
+	}
+	for _, test := range tests {
+		re := MustCompile(test.pattern, 0)
+		got, err := scanAll(re, test.mode, test.input)
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s %q: %q, want %q (%v)",
+				test.pattern, test.input, got, test.want, err)
+		}
+		re.FreeRegexp()
+	}
+}
+
+func TestSplitFuncError(t *testing.T) {
+	re := MustCompile(`.`, UTF8)
+	defer re.FreeRegexp()
+	if _, err := scanAll(re, SplitTokens, "ab\xffc"); err == nil {
+		t.Error("invalid UTF-8 accepted")
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		n          int
+		want       []string
+	}{
+		{`,`, "a,b,c", -1, []string{"a", "b", "c"}},
+		{`,`, "a,b,c", 2, []string{"a", "b,c"}},
+		{`,`, "a,b,c", 0, nil},
+		{`,`, ",a,", -1, []string{"", "a", ""}},
+		{`,`, "", -1, []string{""}},
+		{`x*`, "axbc", -1, []string{"a", "b", "c"}},
+		{`\s+`, "  lead", -1, []string{"", "lead"}},
+		{`\d`, "abc", -1, []string{"abc"}},
+		{``, "", -1, []string{}},
+		{``, "ab", -1, []string{"a", "b"}},
+		{`(?:)`, "", -1, []string{""}},
+	}
+	for _, test := range tests {
+		re := MustCompile(test.pattern, 0)
+		if got := re.SplitString(test.s, test.n, 0); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("SplitString(%q, %q, %d) = %q, want %q",
+				test.pattern, test.s, test.n, got, test.want)
+		}
+		got := re.Split([]byte(test.s), test.n, 0)
+		if len(got) != len(test.want) {
+			t.Errorf("Split(%q, %q, %d) = %q", test.pattern, test.s, test.n, got)
+		}
+		for i := range got {
+			if i < len(test.want) && string(got[i]) != test.want[i] {
+				t.Errorf("Split(%q, %q, %d) = %q", test.pattern, test.s, test.n, got)
+				break
+			}
+		}
+		re.FreeRegexp()
+	}
+}
+
+func TestSplitSubmatch(t *testing.T) {
+	re := MustCompile(`\s*([,;])(!)?\s*`, 0)
+	defer re.FreeRegexp()
+	got := re.SplitStringSubmatch("a , b;!c", -1, 0)
+	want := []string{"a", ",", "", "b", ";", "!", "c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SplitStringSubmatch = %q, want %q", got, want)
+	}
+	got = re.SplitStringSubmatch("a,b,c", 2, 0)
+	want = []string{"a", ",", "", "b,c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SplitStringSubmatch n=2 = %q, want %q", got, want)
+	}
+	b := re.SplitSubmatch([]byte("x;y"), -1, 0)
+	if len(b) != 4 || string(b[1]) != ";" || b[2] != nil || string(b[3]) != "y" {
+		t.Errorf("SplitSubmatch = %q", b)
+	}
+}
@@ -0,0 +1,204 @@
+package pcre
+
+import (
+	"io"
+	"unicode/utf8"
+)
+
+// defaultChunkSize is the number of bytes a StreamMatcher reads at once.
+const defaultChunkSize = 64 * 1024
+
+// StreamMatcher finds successive non-overlapping matches in the text
+// read from an io.Reader, without holding all of it in memory.  While
+// more input may follow, it matches with PARTIAL_HARD, so that a match
+// cut off by the end of the data read so far is retried once more data
+// has arrived.  It retains only the text of such pending matches plus
+// the context needed by the lookbehinds of the pattern, as reported by
+// Info.MaxLookbehind, so its memory use is bounded by the chunk size
+// and the length of the longest match.  Matches are reported as with
+// FindAllIndex, with absolute offsets in the stream.
+type StreamMatcher struct {
+	m      *Matcher
+	r      io.Reader
+	flags  int
+	chunk  int
+	retain int     // bytes of context kept before the search position
+	st     stepper // steps past empty matches
+	buf    []byte
+	base   int64 // stream offset of buf[0]
+	pos    int   // offset in buf where the next search starts
+	limit  int   // end of the text in buf that is searched
+	eof    bool
+	// prevMatchEnd is the stream offset of the end of the last match,
+	// or -1, to skip empty matches right after a match.
+	prevMatchEnd int64
+	err          error
+}
+
+// NewStreamMatcher creates a StreamMatcher that reads from r and
+// matches with the given flags.
+func (re Regexp) NewStreamMatcher(r io.Reader, flags int) *StreamMatcher {
+	s := &StreamMatcher{
+		m:            re.NewMatcher(),
+		r:            r,
+		flags:        flags,
+		chunk:        defaultChunkSize,
+		prevMatchEnd: -1,
+	}
+	info, err := re.Info()
+	if err != nil {
+		s.err = err
+		return s
+	}
+	// Keep one character more than the longest lookbehind, for the
+	// multiline ^ assertion, which looks at the previous character.
+	s.retain = info.MaxLookbehind + 1
+	s.st = re.stepper(flags)
+	if s.st.utf {
+		s.retain *= utf8.UTFMax
+	}
+	return s
+}
+
+// SetChunkSize sets the number of bytes read from the stream at once.
+func (s *StreamMatcher) SetChunkSize(n int) {
+	if n < 1 {
+		n = 1
+	}
+	s.chunk = n
+}
+
+// Err returns the first error encountered while reading or matching.
+// Reaching the end of the stream is not an error.
+func (s *StreamMatcher) Err() error {
+	return s.err
+}
+
+// Next finds the next match in the stream.  It returns false when
+// there are no more matches, or after an error.  The offsets and text
+// of the match are available until the next call to Next.
+func (s *StreamMatcher) Next() bool {
+	for s.err == nil {
+		if !s.eof && s.pos >= s.limit {
+			s.fill()
+			continue
+		}
+		if s.pos > s.limit {
+			return false
+		}
+		flags := s.flags
+		if !s.eof {
+			flags |= PARTIAL_HARD
+		}
+		if !s.m.MatchFrom(s.buf[:s.limit], s.pos, flags) {
+			if s.err = s.m.Err(); s.err != nil || s.eof {
+				return false
+			}
+			// Nothing can match before the end of the data.
+			s.pos = s.limit
+			continue
+		}
+		loc := s.m.ovector
+		if s.m.Partial() {
+			// Retry from the start of the partial match once
+			// more data has been read.  That is loc[2]; loc[0]
+			// is the first character inspected, which includes
+			// lookbehind and may precede s.pos.  fill keeps the
+			// context before it.
+			s.pos = maxInt(s.pos, int(loc[2]))
+			s.fill()
+			continue
+		}
+		start, end := int(loc[0]), int(loc[1])
+		accept := true
+		if end == s.pos {
+			// An empty match right after the previous match is
+			// skipped; always advance to make progress.
+			if s.base+int64(start) == s.prevMatchEnd {
+				accept = false
+			}
+			s.pos = s.st.step(s.buf[:s.limit], "", s.pos)
+		} else {
+			s.pos = end
+		}
+		s.prevMatchEnd = s.base + int64(end)
+		if accept {
+			return true
+		}
+	}
+	return false
+}
+
+// fill drops the text that is no longer needed and reads more data.
+func (s *StreamMatcher) fill() {
+	if cut := s.pos - s.retain; cut > 0 {
+		if s.st.utf {
+			for cut > 0 && !utf8.RuneStart(s.buf[cut]) {
+				cut--
+			}
+		}
+		n := copy(s.buf, s.buf[cut:])
+		s.buf = s.buf[:n]
+		s.base += int64(cut)
+		s.pos -= cut
+	}
+	if cap(s.buf)-len(s.buf) < s.chunk {
+		buf := make([]byte, len(s.buf), 2*len(s.buf)+s.chunk)
+		copy(buf, s.buf)
+		s.buf = buf
+	}
+	n, err := s.r.Read(s.buf[len(s.buf) : len(s.buf)+s.chunk])
+	s.buf = s.buf[:len(s.buf)+n]
+	switch {
+	case err == io.EOF:
+		s.eof = true
+	case err != nil:
+		s.err = err
+	}
+	s.limit = len(s.buf)
+	if s.st.utf && !s.eof {
+		// Leave a character cut off by the end of the data for
+		// the next read, as PCRE rejects it with ERROR_SHORTUTF8.
+		for i := 1; i < utf8.UTFMax && i <= s.limit; i++ {
+			if utf8.RuneStart(s.buf[s.limit-i]) {
+				if !utf8.FullRune(s.buf[s.limit-i:]) {
+					s.limit -= i
+				}
+				break
+			}
+		}
+	}
+	if s.st.crlf && !s.eof && s.limit > 0 && s.buf[s.limit-1] == '\r' {
+		// Likewise leave a CR that may start a CRLF newline.
+		s.limit--
+	}
+}
+
+// Index returns the start and end offsets of the match in the stream.
+func (s *StreamMatcher) Index() []int64 {
+	return s.GroupIndices(0)
+}
+
+// GroupIndices returns the start and end offsets in the stream of the
+// numbered capture group of the match, or nil if the group is not
+// present.
+func (s *StreamMatcher) GroupIndices(group int) []int64 {
+	loc := s.m.GroupIndices(group)
+	if loc == nil {
+		return nil
+	}
+	return []int64{s.base + int64(loc[0]), s.base + int64(loc[1])}
+}
+
+// Group returns the text of the numbered capture group of the match,
+// or nil if the group is not present.  The slice is only valid until
+// the next call to Next.
+func (s *StreamMatcher) Group(group int) []byte {
+	return s.m.Group(group)
+}
+
+// GroupString returns the text of the numbered capture group of the
+// match, or an empty string if the group is not present.
+func (s *StreamMatcher) GroupString(group int) string {
+	return s.m.GroupString(group)
+}
@@ -0,0 +1,127 @@
+package pcre
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"testing/iotest"
+)
+
+// streamIndex collects the match offsets of a StreamMatcher.
+func streamIndex(s *StreamMatcher) [][]int {
+	var result [][]int
+	for s.Next() {
+		loc := s.Index()
+		result = append(result, []int{int(loc[0]), int(loc[1])})
+	}
+	return result
+}
+
+func TestStreamMatcher(t *testing.T) {
+	tests := []struct {
+		pattern string
+		flags   int
+		subject string
+	}{
+		{`\d+`, 0, "a1b22c333d4444e55555"},
+		{`a.*?b`, 0, "xaxxbyaab aaaab"},
+		{`(?<=ab)c+`, 0, "abccc abc xbc abcc"},
+		{`\bfoo\b`, 0, "foo foobar barfoo foo"},
+		{`^line\d`, MULTILINE, "line1\nxline2\nline3"},
+		{`x*`, 0, "axxbxc"},
+		{`end$`, 0, "the end is not the end"},
+		{`(?<=é)ü+`, UTF8, "aéüüb éü éxü"},
+		{`a|(?<=a)bc`, 0, "abc"},
+		{`x*`, UTF8, "éx€üx"},
+		{`x*`, NEWLINE_CRLF, "a\r\n\r\nx\r"},
+		{`(?m)$`, NEWLINE_ANYCRLF, "a\r\nb\r\n"},
+	}
+	for _, test := range tests {
+		re := MustCompile(test.pattern, test.flags)
+		want := re.FindAllIndex([]byte(test.subject), -1, 0)
+		for chunk := 1; chunk <= 8; chunk++ {
+			s := re.NewStreamMatcher(
+				iotest.HalfReader(bytes.NewReader([]byte(test.subject))), 0)
+			s.SetChunkSize(chunk)
+			got := streamIndex(s)
+			if s.Err() != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("%s chunk %d: %v, want %v (%v)",
+					test.pattern, chunk, got, want, s.Err())
+			}
+		}
+		re.FreeRegexp()
+	}
+}
+
+func TestStreamMatcherGroups(t *testing.T) {
+	re := MustCompile(`(\w+)=(\d+)`, 0)
+	defer re.FreeRegexp()
+	s := re.NewStreamMatcher(bytes.NewReader([]byte("a=1, bb=22, ccc=x")), 0)
+	s.SetChunkSize(3)
+	var pairs []string
+	for s.Next() {
+		pairs = append(pairs, s.GroupString(1)+":"+string(s.Group(2)))
+	}
+	if !reflect.DeepEqual(pairs, []string{"a:1", "bb:22"}) || s.Err() != nil {
+		t.Error("groups", pairs, s.Err())
+	}
+}
+
+// repeatReader returns n bytes of b repeated, followed by tail.
+type repeatReader struct {
+	b    byte
+	n    int
+	tail []byte
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	if r.n == 0 {
+		if len(r.tail) == 0 {
+			return 0, io.EOF
+		}
+		n := copy(p, r.tail)
+		r.tail = r.tail[n:]
+		return n, nil
+	}
+	if len(p) > r.n {
+		p = p[:r.n]
+	}
+	for i := range p {
+		p[i] = r.b
+	}
+	r.n -= len(p)
+	return len(p), nil
+}
+
+func TestStreamMatcherMemory(t *testing.T) {
+	re := MustCompile(`(?<=x{3})y`, 0)
+	defer re.FreeRegexp()
+	const size = 4 << 20
+	s := re.NewStreamMatcher(&repeatReader{'x', size, []byte("y")}, 0)
+	s.SetChunkSize(1024)
+	if !s.Next() {
+		t.Fatal("no match", s.Err())
+	}
+	if loc := s.Index(); loc[0] != size || loc[1] != size+1 {
+		t.Error("Index", loc)
+	}
+	if cap(s.buf) > 4096 {
+		t.Error("buffer grew to", cap(s.buf))
+	}
+	if s.Next() {
+		t.Error("extra match")
+	}
+}
+
+func TestStreamMatcherError(t *testing.T) {
+	re := MustCompile(`a`, 0)
+	defer re.FreeRegexp()
+	s := re.NewStreamMatcher(
+		iotest.TimeoutReader(bytes.NewReader([]byte("xxxaxxxa"))), 0)
+	s.SetChunkSize(4)
+	if !s.Next() || s.Next() || !errors.Is(s.Err(), iotest.ErrTimeout) {
+		t.Error("read error", s.Err())
+	}
+}
@@ -0,0 +1,371 @@
+package pcre
+
+import (
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Template is a parsed replacement template, for use with Matcher.Expand
+// and Regexp.ReplaceAllTemplate.  In a template,
+//
+//	$n and ${n}      stand for capture group n, and \n for group n <= 9
+//	$name, ${name}   stand for the named capture group
+//	$$               stands for a literal $
+//	\U and \L        convert the rest of the text to upper or lower case
+//	\u and \l        convert the next character to upper or lower case
+//	\E               ends \U and \L
+//	${n:+set:unset}  stands for set if group n is present, else unset
+//	${n:-default}    stands for group n if present, else default
+//
+// The texts of conditional references may contain references of their
+// own.  A backslash before any other character, such as $, \, : or },
+// stands for that character.  Groups that are not present stand for the
+// empty string.  With DUPNAMES, a name stands for the first of its
+// groups that is present.
+//
+// A Template holds the group numbers of the Regexp it was compiled for,
+// and must only be used with matches of that Regexp.  It may be used
+// from several goroutines at once.
+type Template struct {
+	template string
+	items    []templateItem
+}
+
+// templateItem is a literal text, a group reference, a case conversion
+// or a conditional reference.
+type templateItem struct {
+	literal string
+	groups  []int // referenced groups, in order of preference
+	convert byte  // 'U', 'L', 'E', 'u' or 'l'
+	// For a conditional reference, set and unset are the items
+	// expanded if one of groups is present or not.
+	conditional bool
+	set, unset  []templateItem
+}
+
+// TemplateError holds details about an invalid template, as returned by
+// Regexp.CompileTemplate.  The offset is the byte position in the
+// template at which the error was detected.
+type TemplateError struct {
+	Template string // The invalid template
+	Message  string // The error message
+	Offset   int    // Byte position of error
+}
+
+// Error converts a template error to a string.
+func (e *TemplateError) Error() string {
+	return e.Template + " (" + strconv.Itoa(e.Offset) + "): " + e.Message
+}
+
+// CompileTemplate parses a replacement template for matches of re.
+// Invalid templates, including those referring to groups that re does
+// not have, return a *TemplateError.
+func (re Regexp) CompileTemplate(template string) (*Template, error) {
+	p := &templateParser{
+		template: template,
+		groups:   re.Groups(),
+		names:    re.SubexpIndices(),
+	}
+	items, err := p.parse(false)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{template, items}, nil
+}
+
+// MustCompileTemplate is like CompileTemplate, but panics if the
+// template is invalid.
+func (re Regexp) MustCompileTemplate(template string) *Template {
+	t, err := re.CompileTemplate(template)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// String returns the source text of the template.
+func (t *Template) String() string {
+	return t.template
+}
+
+type templateParser struct {
+	template string
+	pos      int
+	groups   int
+	names    map[string][]int
+}
+
+func (p *templateParser) error(message string) *TemplateError {
+	return &TemplateError{p.template, message, p.pos}
+}
+
+// parse parses items up to the end of the template, or in the text of
+// a conditional reference, up to an unescaped : or }.
+func (p *templateParser) parse(conditional bool) ([]templateItem, error) {
+	var items []templateItem
+	var literal []byte
+	flush := func() {
+		if len(literal) > 0 {
+			items = append(items, templateItem{literal: string(literal)})
+			literal = nil
+		}
+	}
+	t := p.template
+	for p.pos < len(t) {
+		c := t[p.pos]
+		switch {
+		case conditional && (c == ':' || c == '}'):
+			flush()
+			return items, nil
+		case c == '\\':
+			p.pos++
+			if p.pos == len(t) {
+				return nil, p.error("trailing backslash")
+			}
+			c = t[p.pos]
+			switch {
+			case c >= '0' && c <= '9':
+				item, err := p.groupRef(int(c - '0'))
+				if err != nil {
+					return nil, err
+				}
+				flush()
+				items = append(items, item)
+				p.pos++
+			case c == 'U' || c == 'L' || c == 'E' || c == 'u' || c == 'l':
+				flush()
+				items = append(items, templateItem{convert: c})
+				p.pos++
+			default:
+				_, size := utf8.DecodeRuneInString(t[p.pos:])
+				literal = append(literal, t[p.pos:p.pos+size]...)
+				p.pos += size
+			}
+		case c == '$':
+			p.pos++
+			if p.pos < len(t) && t[p.pos] == '$' {
+				literal = append(literal, '$')
+				p.pos++
+				continue
+			}
+			item, err := p.reference()
+			if err != nil {
+				return nil, err
+			}
+			flush()
+			items = append(items, item)
+		default:
+			literal = append(literal, c)
+			p.pos++
+		}
+	}
+	flush()
+	return items, nil
+}
+
+// reference parses the group reference after a $.
+func (p *templateParser) reference() (templateItem, error) {
+	braced := p.pos < len(p.template) && p.template[p.pos] == '{'
+	if braced {
+		p.pos++
+	}
+	start := p.pos
+	item, err := p.groupName()
+	if err != nil || !braced {
+		return item, err
+	}
+	t := p.template
+	if p.pos+1 < len(t) && t[p.pos] == ':' && (t[p.pos+1] == '+' || t[p.pos+1] == '-') {
+		plus := t[p.pos+1] == '+'
+		p.pos += 2
+		item.conditional = true
+		if plus {
+			if item.set, err = p.parse(true); err != nil {
+				return item, err
+			}
+			if p.pos < len(t) && t[p.pos] == ':' {
+				p.pos++
+				if item.unset, err = p.parse(true); err != nil {
+					return item, err
+				}
+			}
+		} else {
+			item.set = []templateItem{{groups: item.groups}}
+			if item.unset, err = p.parse(true); err != nil {
+				return item, err
+			}
+		}
+	}
+	if p.pos == len(t) || t[p.pos] != '}' {
+		p.pos = start - 2
+		return item, p.error("missing } in group reference")
+	}
+	p.pos++
+	return item, nil
+}
+
+// groupName parses a group number or name.
+func (p *templateParser) groupName() (templateItem, error) {
+	t := p.template
+	start := p.pos
+	for p.pos < len(t) && t[p.pos] >= '0' && t[p.pos] <= '9' {
+		p.pos++
+	}
+	if end := p.pos; end > start {
+		n, err := strconv.Atoi(t[start:end])
+		if err != nil {
+			n = p.groups + 1 // too large
+		}
+		p.pos = start
+		item, err := p.groupRef(n)
+		p.pos = end
+		return item, err
+	}
+	for p.pos < len(t) && isNameChar(t[p.pos], p.pos == start) {
+		p.pos++
+	}
+	if p.pos == start {
+		return templateItem{}, p.error("invalid group reference")
+	}
+	name := t[start:p.pos]
+	groups, ok := p.names[name]
+	if !ok {
+		p.pos = start
+		return templateItem{}, p.error("reference to non-existent group " + name)
+	}
+	return templateItem{groups: groups}, nil
+}
+
+// groupRef returns a reference to group n at the current position.
+func (p *templateParser) groupRef(n int) (templateItem, error) {
+	if n > p.groups {
+		return templateItem{}, p.error("reference to non-existent group " +
+			strconv.Itoa(n))
+	}
+	return templateItem{groups: []int{n}}, nil
+}
+
+func isNameChar(c byte, first bool) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' ||
+		!first && c >= '0' && c <= '9'
+}
+
+// Expand appends the template to dst, with its references replaced by
+// the capture groups of the last match of m, and returns the result.
+func (m *Matcher) Expand(dst []byte, t *Template) []byte {
+	var conv caseConversion
+	return m.expand(dst, t.items, &conv)
+}
+
+func (m *Matcher) expand(dst []byte, items []templateItem, conv *caseConversion) []byte {
+	for _, item := range items {
+		switch {
+		case item.convert != 0:
+			conv.set(item.convert)
+		case item.conditional:
+			if m.anyPresent(item.groups) {
+				dst = m.expand(dst, item.set, conv)
+			} else {
+				dst = m.expand(dst, item.unset, conv)
+			}
+		case item.groups != nil:
+			for _, group := range item.groups {
+				if m.matches && group <= m.groups && m.Present(group) {
+					start, end := m.ovector[2*group], m.ovector[2*group+1]
+					if m.subjectb != nil {
+						dst = conv.appendBytes(dst, m.subjectb[start:end])
+					} else {
+						dst = conv.appendString(dst, m.subjects[start:end])
+					}
+					break
+				}
+			}
+		default:
+			dst = conv.appendString(dst, item.literal)
+		}
+	}
+	return dst
+}
+
+func (m *Matcher) anyPresent(groups []int) bool {
+	for _, group := range groups {
+		if m.matches && group <= m.groups && m.Present(group) {
+			return true
+		}
+	}
+	return false
+}
+
+// caseConversion holds the state of the case conversions of \U, \L,
+// \u and \l in a template.
+type caseConversion struct {
+	mode byte // 'U' or 'L' until \E, or 0
+	next byte // 'U' or 'L' for the next character, or 0
+}
+
+func (conv *caseConversion) set(c byte) {
+	switch c {
+	case 'U', 'L':
+		conv.mode = c
+	case 'E':
+		conv.mode, conv.next = 0, 0
+	case 'u':
+		conv.next = 'U'
+	case 'l':
+		conv.next = 'L'
+	}
+}
+
+func (conv *caseConversion) appendBytes(dst, b []byte) []byte {
+	if conv.mode == 0 && conv.next == 0 {
+		return append(dst, b...)
+	}
+	return conv.appendString(dst, string(b))
+}
+
+func (conv *caseConversion) appendString(dst []byte, s string) []byte {
+	if conv.mode == 0 && conv.next == 0 {
+		return append(dst, s...)
+	}
+	var buf [utf8.UTFMax]byte
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		if r == utf8.RuneError && size == 1 {
+			// Keep invalid UTF-8 as it is.
+			dst = append(dst, s[0])
+			s = s[1:]
+			continue
+		}
+		s = s[size:]
+		to := conv.mode
+		if conv.next != 0 {
+			to, conv.next = conv.next, 0
+		}
+		switch to {
+		case 'U':
+			r = unicode.ToUpper(r)
+		case 'L':
+			r = unicode.ToLower(r)
+		}
+		dst = append(dst, buf[:utf8.EncodeRune(buf[:], r)]...)
+	}
+	return dst
+}
+
+// ReplaceAllTemplate returns a copy of src in which all matches of re
+// are replaced by the expansion of the template t.  An empty match
+// immediately following a previous match is not replaced.
+func (re Regexp) ReplaceAllTemplate(src []byte, t *Template, flags int) ([]byte, error) {
+	m := re.getMatcher()
+	defer re.putMatcher(m)
+	return m.replaceAllFunc(src, flags, func(dst []byte) ([]byte, error) {
+		return m.Expand(dst, t), nil
+	})
+}
+
+// ReplaceAllStringTemplate is like ReplaceAllTemplate, with strings.
+func (re Regexp) ReplaceAllStringTemplate(src string, t *Template, flags int) (string, error) {
+	r, err := re.ReplaceAllTemplate([]byte(src), t, flags)
+	return string(r), err
+}
@@ -0,0 +1,79 @@
+package pcre
+
+import (
+	"testing"
+)
+
+var templateTests = []struct {
+	pattern, template, subject, want string
+}{
+	{`(\w+) (\w+)`, `$2 $1`, "hello world", "world hello"},
+	{`(\w+) (\w+)`, `\2-\1-\0`, "hello world", "world-hello-hello world"},
+	{`(?<first>\w+) (?<last>\w+)`, `${last}, $first`, "ada lovelace", "lovelace, ada"},
+	{`(\d+)`, `$$$1.00`, "cost 5", "cost $5.00"},
+	{`(\d+)`, `${1}0`, "5", "50"},
+	{`(\w+) (\w+)`, `\U$1\E $2`, "hello world", "HELLO world"},
+	{`(\w+) (\w+)`, `\u$1 \L\u$2`, "hello WORLD", "Hello World"},
+	{`(\w+)`, `\Ufoo\lBAR`, "x", "FOObAR"},
+	{`(a)?b`, `${1:+yes:no}`, "ab b", "yes no"},
+	{`(a)?b`, `[${1:+\$1=$1}]`, "ab b", "[$1=a] []"},
+	{`(a)?b`, `${1:-none}`, "ab b", "a none"},
+	{`(é)`, `\U$1`, "café", "cafÉ"},
+	{`(\w+)`, `\\\:\}`, "x", `\:}`},
+	{`(?<d>\d{4})-\d\d|\d\d/(?<d>\d\d)`, `<$d>`, "2024-05 05/17", "<2024> <17>"},
+}
+
+func TestTemplate(t *testing.T) {
+	for _, test := range templateTests {
+		re := MustCompile(test.pattern, DUPNAMES|UTF8)
+		tmpl, err := re.CompileTemplate(test.template)
+		if err != nil {
+			t.Errorf("CompileTemplate(%q): %v", test.template, err)
+			re.Close()
+			continue
+		}
+		got, err := re.ReplaceAllStringTemplate(test.subject, tmpl, 0)
+		if err != nil || got != test.want {
+			t.Errorf("%s %s %q: %q, want %q (%v)", test.pattern,
+				test.template, test.subject, got, test.want, err)
+		}
+		re.Close()
+	}
+}
+
+func TestExpand(t *testing.T) {
+	re := MustCompile(`(\w+)@(\w+)`, 0)
+	defer re.Close()
+	tmpl := re.MustCompileTemplate(`user=$1 host=$2`)
+	m := re.MatcherString("mail bob@example now", 0)
+	if got := string(m.Expand([]byte("> "), tmpl)); got != "> user=bob host=example" {
+		t.Error("Expand", got)
+	}
+	if tmpl.String() != `user=$1 host=$2` {
+		t.Error("String", tmpl.String())
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	re := MustCompile(`(?<word>\w+) (\d)`, 0)
+	defer re.Close()
+	for template, offset := range map[string]int{
+		`$3`:                    1,
+		`ab\9`:                  3,
+		`x${nope}`:              3,
+		`$word${2`:              5,
+		`${1:+a:b:c}`:           0,
+		`$`:                     1,
+		`$-`:                    1,
+		`trailing\`:             9,
+		`$99999999999999999999`: 1,
+	} {
+		_, err := re.CompileTemplate(template)
+		e, ok := err.(*TemplateError)
+		if !ok {
+			t.Errorf("%q: got %v, want a *TemplateError", template, err)
+		} else if e.Offset != offset {
+			t.Errorf("%q: offset %d, want %d (%v)", template, e.Offset, offset, e)
+		}
+	}
+}
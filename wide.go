@@ -0,0 +1,495 @@
+package pcre
+
+// #include <stdint.h>
+// #include "./pcre.h"
+// // The go_pcre_w functions call the 16 or 32-bit library by width.
+// static void *go_pcre_wcompile(int width, const void *pattern,
+//         int options, const char **errptr, int *erroffset) {
+//     if (width == 16)
+//         return pcre16_compile(pattern, options, errptr, erroffset, NULL);
+//     return pcre32_compile(pattern, options, errptr, erroffset, NULL);
+// }
+// static void *go_pcre_wstudy(int width, const void *code, int options,
+//         const char **errptr) {
+//     if (width == 16)
+//         return pcre16_study(code, options, errptr);
+//     return pcre32_study(code, options, errptr);
+// }
+// static int go_pcre_wfullinfo(int width, const void *code,
+//         const void *extra, int what, void *where) {
+//     if (width == 16)
+//         return pcre16_fullinfo(code, extra, what, where);
+//     return pcre32_fullinfo(code, extra, what, where);
+// }
+// static int go_pcre_wexec(int width, const void *code,
+//         const void *extra, const void *subject, int length,
+//         int start, int options, int *ovector, int ovecsize) {
+//     if (width == 16)
+//         return pcre16_exec(code, extra, subject, length, start,
+//             options, ovector, ovecsize);
+//     return pcre32_exec(code, extra, subject, length, start,
+//         options, ovector, ovecsize);
+// }
+// // go_pcre_wnamegroups stores the numbers of the groups called name
+// // in groups, and returns how many there are.
+// static int go_pcre_wnamegroups(int width, const void *code,
+//         const void *name, int *groups, int max) {
+//     int size, n = 0;
+//     if (width == 16) {
+//         PCRE_UCHAR16 *first, *last;
+//         size = pcre16_get_stringtable_entries(code, name, &first, &last);
+//         for (; size > 0 && first <= last && n < max; first += size)
+//             groups[n++] = first[0];
+//     } else {
+//         PCRE_UCHAR32 *first, *last;
+//         size = pcre32_get_stringtable_entries(code, name, &first, &last);
+//         for (; size > 0 && first <= last && n < max; first += size)
+//             groups[n++] = first[0];
+//     }
+//     return size < 0 ? size : n;
+// }
+// static void go_pcre_wfree(int width, void *code, void *extra) {
+//     if (width == 16) {
+//         if (extra != NULL)
+//             pcre16_free_study(extra);
+//         pcre16_free(code);
+//     } else {
+//         if (extra != NULL)
+//             pcre32_free_study(extra);
+//         pcre32_free(code);
+//     }
+// }
+import "C"
+
+import (
+	"fmt"
+	"runtime"
+	"unicode/utf16"
+	"unsafe"
+)
+
+// Flags for Compile16 and Compile32, and for their match functions.
+// They are synonyms of UTF8 and NO_UTF8_CHECK.
+const (
+	UTF16          = C.PCRE_UTF16
+	UTF32          = C.PCRE_UTF32
+	NO_UTF16_CHECK = C.PCRE_NO_UTF16_CHECK
+	NO_UTF32_CHECK = C.PCRE_NO_UTF32_CHECK
+)
+
+// Regexp16 holds a pattern compiled by the 16-bit library of PCRE,
+// which matches []uint16 subjects such as UTF-16 text.  With UTF16,
+// subjects must be valid UTF-16.  Offsets are in 16-bit code units.
+type Regexp16 struct {
+	wideRegexp
+}
+
+// Regexp32 holds a pattern compiled by the 32-bit library of PCRE,
+// which matches []rune subjects.  With UTF32, subjects must be valid
+// Unicode code points.  Offsets are in runes.
+type Regexp32 struct {
+	wideRegexp
+}
+
+// wideRegexp holds the methods shared by Regexp16 and Regexp32.
+type wideRegexp struct {
+	c *code
+}
+
+// Compile16 compiles the pattern for the 16-bit library.  The pattern
+// is converted to UTF-16; flags are those of Compile, with UTF16 in
+// place of UTF8.  If compilation fails, the second return value holds
+// a *CompileError.
+func Compile16(pattern string, flags int) (Regexp16, error) {
+	units := append(utf16.Encode([]rune(pattern)), 0)
+	c, err := compileWide(16, pattern, unsafe.Pointer(&units[0]), flags)
+	return Regexp16{wideRegexp{c}}, err
+}
+
+// Compile32 compiles the pattern for the 32-bit library.  Flags are
+// those of Compile, with UTF32 in place of UTF8.  If compilation fails,
+// the second return value holds a *CompileError.
+func Compile32(pattern string, flags int) (Regexp32, error) {
+	units := append([]rune(pattern), 0)
+	c, err := compileWide(32, pattern, unsafe.Pointer(&units[0]), flags)
+	return Regexp32{wideRegexp{c}}, err
+}
+
+// MustCompile16 compiles the pattern with Compile16.  If compilation
+// fails, panic.
+func MustCompile16(pattern string, flags int) Regexp16 {
+	re, err := Compile16(pattern, flags)
+	if err != nil {
+		panic(err)
+	}
+	return re
+}
+
+// MustCompile32 compiles the pattern with Compile32.  If compilation
+// fails, panic.
+func MustCompile32(pattern string, flags int) Regexp32 {
+	re, err := Compile32(pattern, flags)
+	if err != nil {
+		panic(err)
+	}
+	return re
+}
+
+func compileWide(width int, pattern string, units unsafe.Pointer, flags int) (*code, error) {
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == 0 {
+			return nil, &CompileError{
+				Pattern: pattern,
+				Message: "NUL byte in pattern",
+				Offset:  i,
+			}
+		}
+	}
+	var errptr *C.char
+	var erroffset C.int
+	ptr := C.go_pcre_wcompile(C.int(width), units, C.int(flags),
+		&errptr, &erroffset)
+	if ptr == nil {
+		return nil, &CompileError{
+			Pattern: pattern,
+			Message: C.GoString(errptr),
+			Offset:  byteOffset(pattern, width, int(erroffset)),
+		}
+	}
+	c := &code{ptr: (*C.pcre)(ptr), width: width, refs: 1}
+	var groups C.int
+	c.fullinfo(C.PCRE_INFO_CAPTURECOUNT, unsafe.Pointer(&groups))
+	c.groups = int(groups)
+	runtime.SetFinalizer(c, (*code).free)
+	return c, nil
+}
+
+// byteOffset converts an offset in code units of the converted
+// pattern to a byte offset in pattern.
+func byteOffset(pattern string, width, units int) int {
+	for i, r := range pattern {
+		if units <= 0 {
+			return i
+		}
+		units--
+		if width == 16 && r >= 0x10000 {
+			units-- // surrogate pair
+		}
+	}
+	return len(pattern)
+}
+
+func (c *code) fullinfo(what C.int, where unsafe.Pointer) C.int {
+	return C.go_pcre_wfullinfo(C.int(c.width), unsafe.Pointer(c.ptr),
+		unsafe.Pointer(c.extra), what, where)
+}
+
+// freeWide frees the C memory of a pattern of Compile16 or Compile32.
+func (c *code) freeWide() {
+	if c.ptr != nil {
+		C.go_pcre_wfree(C.int(c.width), unsafe.Pointer(c.ptr),
+			unsafe.Pointer(c.extra))
+		c.ptr, c.extra = nil, nil
+	}
+}
+
+// Close frees the C memory of the compiled pattern.  See Regexp.Close.
+func (re *wideRegexp) Close() error {
+	r := Regexp{re.c}
+	return r.Close()
+}
+
+// FreeRegexp is equivalent to Close, ignoring the error.
+func (re *wideRegexp) FreeRegexp() {
+	re.Close()
+}
+
+// Groups returns the number of capture groups in the compiled pattern.
+func (re wideRegexp) Groups() int {
+	if re.c == nil {
+		panic("Regexp: uninitialized")
+	}
+	return re.c.groups
+}
+
+// Study optimizes the pattern, by default with JIT compilation.  See
+// Regexp.Study.  Study must not be called while the pattern is in use
+// by other goroutines.
+func (re *wideRegexp) Study(flags int) error {
+	if re.c == nil {
+		panic("Regexp.Study: uninitialized")
+	}
+	if !re.c.acquire() {
+		return ErrFreed
+	}
+	defer re.c.release()
+	if re.c.extra != nil {
+		return fmt.Errorf("Study: Regexp has already been optimized")
+	}
+	if flags == 0 {
+		flags = STUDY_JIT_COMPILE
+	}
+	var err *C.char
+	extra := C.go_pcre_wstudy(C.int(re.c.width), unsafe.Pointer(re.c.ptr),
+		C.int(flags), &err)
+	if err != nil {
+		return fmt.Errorf("%s", C.GoString(err))
+	}
+	re.c.extra = (*C.pcre_extra)(extra)
+	return nil
+}
+
+// Matcher16 matches a Regexp16 against []uint16 subjects and stores
+// the results.  Offsets are in 16-bit code units.
+type Matcher16 struct {
+	wideMatcher
+	subject []uint16
+}
+
+// Matcher32 matches a Regexp32 against []rune subjects and stores the
+// results.  Offsets are in runes.
+type Matcher32 struct {
+	wideMatcher
+	subject []rune
+}
+
+// NewMatcher creates a new matcher object for the given Regexp16.
+func (re Regexp16) NewMatcher() *Matcher16 {
+	m := new(Matcher16)
+	m.init(re.c)
+	return m
+}
+
+// NewMatcher creates a new matcher object for the given Regexp32.
+func (re Regexp32) NewMatcher() *Matcher32 {
+	m := new(Matcher32)
+	m.init(re.c)
+	return m
+}
+
+// Matcher creates a new matcher object and starts a first match on
+// subject.  Test for success with Matches().
+func (re Regexp16) Matcher(subject []uint16, flags int) *Matcher16 {
+	m := re.NewMatcher()
+	m.Match(subject, flags)
+	return m
+}
+
+// Matcher creates a new matcher object and starts a first match on
+// subject.  Test for success with Matches().
+func (re Regexp32) Matcher(subject []rune, flags int) *Matcher32 {
+	m := re.NewMatcher()
+	m.Match(subject, flags)
+	return m
+}
+
+// Match tries to match the pattern against subject.  Returns true if
+// the match succeeds.  Match is a no-op if err is not nil.
+func (m *Matcher16) Match(subject []uint16, flags int) bool {
+	return m.MatchFrom(subject, 0, flags)
+}
+
+// Match tries to match the pattern against subject.  Returns true if
+// the match succeeds.  Match is a no-op if err is not nil.
+func (m *Matcher32) Match(subject []rune, flags int) bool {
+	return m.MatchFrom(subject, 0, flags)
+}
+
+// MatchFrom is like Match, but the search starts at code unit offset
+// start within subject.
+func (m *Matcher16) MatchFrom(subject []uint16, start, flags int) bool {
+	m.subject = subject
+	if len(subject) == 0 {
+		subject = []uint16{0} // make first character addressable
+	}
+	return m.match(unsafe.Pointer(&subject[0]), len(m.subject), start, flags)
+}
+
+// MatchFrom is like Match, but the search starts at rune offset start
+// within subject.
+func (m *Matcher32) MatchFrom(subject []rune, start, flags int) bool {
+	m.subject = subject
+	if len(subject) == 0 {
+		subject = []rune{0} // make first character addressable
+	}
+	return m.match(unsafe.Pointer(&subject[0]), len(m.subject), start, flags)
+}
+
+// Group returns the numbered capture group of the last match, or nil
+// if the group is not present.
+func (m *Matcher16) Group(group int) []uint16 {
+	if loc := m.GroupIndices(group); loc != nil {
+		return m.subject[loc[0]:loc[1]]
+	}
+	return nil
+}
+
+// Group returns the numbered capture group of the last match, or nil
+// if the group is not present.
+func (m *Matcher32) Group(group int) []rune {
+	if loc := m.GroupIndices(group); loc != nil {
+		return m.subject[loc[0]:loc[1]]
+	}
+	return nil
+}
+
+// GroupString returns the numbered capture group decoded from UTF-16,
+// or an empty string if the group is not present.
+func (m *Matcher16) GroupString(group int) string {
+	return string(utf16.Decode(m.Group(group)))
+}
+
+// GroupString returns the numbered capture group as a string, or an
+// empty string if the group is not present.
+func (m *Matcher32) GroupString(group int) string {
+	return string(m.Group(group))
+}
+
+// Named returns the value of the named capture group, or nil if the
+// group is not present.  If the name does not refer to a group then
+// error is non-nil.  If several groups have the name, the first one
+// that is set is used.
+func (m *Matcher16) Named(name string) ([]uint16, error) {
+	group, err := m.name2index(name)
+	if err != nil {
+		return nil, err
+	}
+	return m.Group(group), nil
+}
+
+// Named returns the value of the named capture group, or nil if the
+// group is not present.  If the name does not refer to a group then
+// error is non-nil.  If several groups have the name, the first one
+// that is set is used.
+func (m *Matcher32) Named(name string) ([]rune, error) {
+	group, err := m.name2index(name)
+	if err != nil {
+		return nil, err
+	}
+	return m.Group(group), nil
+}
+
+// NamedString is like Named, returning the group as a string.
+func (m *Matcher16) NamedString(name string) (string, error) {
+	group, err := m.Named(name)
+	return string(utf16.Decode(group)), err
+}
+
+// NamedString is like Named, returning the group as a string.
+func (m *Matcher32) NamedString(name string) (string, error) {
+	group, err := m.Named(name)
+	return string(group), err
+}
+
+// wideMatcher holds the match state shared by Matcher16 and Matcher32.
+type wideMatcher struct {
+	c       *code
+	groups  int
+	ovector []C.int
+	matches bool
+	partial bool
+	err     error
+}
+
+func (m *wideMatcher) init(c *code) {
+	if c == nil {
+		panic("Matcher.Init: uninitialized")
+	}
+	m.c = c
+	m.groups = c.groups
+	m.ovector = make([]C.int, 3*(1+m.groups))
+}
+
+// match runs a match and records its outcome.
+func (m *wideMatcher) match(subject unsafe.Pointer, length, start, flags int) bool {
+	if m.err != nil {
+		return false
+	}
+	rc := m.exec(subject, length, start, flags)
+	m.partial = rc == ERROR_PARTIAL
+	m.matches, m.err = matched(rc, m.ovector)
+	if e, ok := m.err.(*MatchError); ok {
+		e.setWidth(m.c.width)
+	}
+	return m.matches
+}
+
+func (m *wideMatcher) exec(subject unsafe.Pointer, length, start, flags int) int {
+	if !m.c.acquire() {
+		return ERROR_NULL
+	}
+	defer m.c.release()
+	return int(C.go_pcre_wexec(C.int(m.c.width), unsafe.Pointer(m.c.ptr),
+		unsafe.Pointer(m.c.extra), subject, C.int(length), C.int(start),
+		C.int(flags), &m.ovector[0], C.int(len(m.ovector))))
+}
+
+// Err returns the first error encountered by the matcher.
+func (m *wideMatcher) Err() error {
+	return m.err
+}
+
+// Matches returns true if the previous match succeeded.
+func (m *wideMatcher) Matches() bool {
+	return m.matches
+}
+
+// Partial returns true if the previous match was a partial match.
+func (m *wideMatcher) Partial() bool {
+	return m.partial
+}
+
+// Groups returns the number of groups in the current pattern.
+func (m *wideMatcher) Groups() int {
+	return m.groups
+}
+
+// Present returns true if the numbered capture group is present in
+// the last match.
+func (m *wideMatcher) Present(group int) bool {
+	return m.matches && m.ovector[2*group] >= 0
+}
+
+// GroupIndices returns the start and end of the numbered capture group
+// in code units, or nil if the group is not present.
+func (m *wideMatcher) GroupIndices(group int) []int {
+	if !m.Present(group) {
+		return nil
+	}
+	return []int{int(m.ovector[2*group]), int(m.ovector[2*group+1])}
+}
+
+// Index returns the start and end of the last match in code units, or
+// nil if it failed.
+func (m *wideMatcher) Index() []int {
+	return m.GroupIndices(0)
+}
+
+// name2index converts a group name to the number of the first group
+// of that name that is set, or else the first group of that name.
+func (m *wideMatcher) name2index(name string) (int, error) {
+	if !m.c.acquire() {
+		return 0, ErrFreed
+	}
+	defer m.c.release()
+	var cname unsafe.Pointer
+	if m.c.width == 16 {
+		units := append(utf16.Encode([]rune(name)), 0)
+		cname = unsafe.Pointer(&units[0])
+	} else {
+		units := append([]rune(name), 0)
+		cname = unsafe.Pointer(&units[0])
+	}
+	groups := make([]C.int, m.groups+1)
+	n := int(C.go_pcre_wnamegroups(C.int(m.c.width),
+		unsafe.Pointer(m.c.ptr), cname, &groups[0], C.int(len(groups))))
+	if n <= 0 {
+		return 0, fmt.Errorf("Matcher.Named: unknown name: " + name)
+	}
+	for _, group := range groups[:n] {
+		if m.Present(int(group)) {
+			return int(group), nil
+		}
+	}
+	return int(groups[0]), nil
+}
@@ -0,0 +1,101 @@
+package pcre
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestRegexp16(t *testing.T) {
+	re := MustCompile16(`(?<word>\w+)😀(\d)`, UTF16|UCP)
+	defer re.FreeRegexp()
+	if re.Groups() != 2 {
+		t.Error("Groups", re.Groups())
+	}
+	subject := utf16.Encode([]rune("x 😀 héllo😀7"))
+	m := re.Matcher(subject, 0)
+	if !m.Matches() {
+		t.Fatal("no match", m.Err())
+	}
+	// The emoji takes two code units.
+	if loc := m.Index(); !reflect.DeepEqual(loc, []int{5, 13}) {
+		t.Error("Index", loc)
+	}
+	if !reflect.DeepEqual(m.GroupIndices(1), []int{5, 10}) {
+		t.Error("GroupIndices", m.GroupIndices(1))
+	}
+	if s := m.GroupString(1); s != "héllo" {
+		t.Error("GroupString", s)
+	}
+	if g := m.Group(2); !reflect.DeepEqual(g, []uint16{'7'}) {
+		t.Error("Group", g)
+	}
+	if s, err := m.NamedString("word"); err != nil || s != "héllo" {
+		t.Error("NamedString", s, err)
+	}
+	if _, err := m.Named("nope"); err == nil {
+		t.Error("unknown name")
+	}
+	if m.MatchFrom(subject, 12, 0) || m.Err() != nil {
+		t.Error("MatchFrom")
+	}
+
+	if err := re.Study(0); err != nil {
+		t.Fatal(err)
+	}
+	if m = re.Matcher(subject, 0); m.GroupString(0) != "héllo😀7" {
+		t.Error("JIT", m.GroupString(0))
+	}
+}
+
+func TestRegexp32(t *testing.T) {
+	re := MustCompile32(`(?<d>\d)|(?<d>[😀-😂])`, UTF32|DUPNAMES)
+	defer re.FreeRegexp()
+	m := re.NewMatcher()
+	if !m.Match([]rune("ab😁c"), 0) {
+		t.Fatal("no match", m.Err())
+	}
+	if loc := m.Index(); !reflect.DeepEqual(loc, []int{2, 3}) {
+		t.Error("Index", loc)
+	}
+	if s, _ := m.NamedString("d"); s != "😁" {
+		t.Error("NamedString", s)
+	}
+	if m.Match(nil, 0) || m.Err() != nil {
+		t.Error("empty subject", m.Err())
+	}
+}
+
+func TestWideErrors(t *testing.T) {
+	re16 := MustCompile16(`a`, UTF16)
+	defer re16.FreeRegexp()
+	m16 := re16.Matcher([]uint16{'x', 0xdc00, 'a'}, 0)
+	var e *MatchError
+	if !errors.As(m16.Err(), &e) || !errors.Is(e, ErrBadUTF8) ||
+		e.Offset != 1 || e.Reason != 3 {
+		t.Fatal("UTF-16 error", m16.Err())
+	}
+	const want = "pcre: invalid UTF-16 subject at offset 1: isolated low surrogate"
+	if s := e.Error(); s != want {
+		t.Error("Error", s)
+	}
+
+	re32 := MustCompile32(`a`, UTF32)
+	defer re32.FreeRegexp()
+	m32 := re32.Matcher([]rune{'a', 0x110000}, 0)
+	if !errors.As(m32.Err(), &e) || e.Offset != 1 || e.Reason != 3 {
+		t.Error("UTF-32 error", m32.Err())
+	}
+
+	_, err := Compile16(`é(`, UTF16)
+	var ce *CompileError
+	if !errors.As(err, &ce) || ce.Offset != 3 {
+		t.Error("CompileError", err)
+	}
+
+	re16.Close()
+	if m16 = re16.Matcher([]uint16{'a'}, 0); !errors.Is(m16.Err(), ErrFreed) {
+		t.Error("freed pattern", m16.Err())
+	}
+}